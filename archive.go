@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"log/slog"
+	"time"
+)
+
+// archiveRetention is how long archived tasks are kept before they are purged
+// automatically at startup.
+var archiveRetention = 90 * 24 * time.Hour
+
+func purgeExpiredArchive() {
+	purged, err := purgeArchivedTodoItems(time.Now().Add(-archiveRetention))
+	if err != nil {
+		slog.Error("archive purge failed", "error", err)
+		return
+	}
+	if purged > 0 {
+		slog.Info("purged archived tasks", "count", purged, "older_than", archiveRetention.String())
+	}
+}
+
+func showArchiveWindow(a fyne.App, w fyne.Window) {
+	archiveWindow := a.NewWindow("Archive")
+	archiveWindow.Resize(fyne.NewSize(500, 300))
+
+	var refresh func()
+	refresh = func() {
+		archiveWindow.SetContent(makeArchiveView(a, w, archiveWindow, refresh))
+	}
+	refresh()
+	archiveWindow.Show()
+}
+
+func makeArchiveView(a fyne.App, w fyne.Window, archiveWindow fyne.Window, refresh func()) fyne.CanvasObject {
+	items, err := getArchivedTodoItems()
+	if err != nil {
+		showError(archiveWindow, "load the archive", err)
+	}
+
+	purgeAllButton := widget.NewButtonWithIcon("Purge all", theme.DeleteIcon(), func() {
+		dialog.ShowConfirm("Purge archive", fmt.Sprintf("Permanently delete %d archived tasks?", len(items)), func(ok bool) {
+			if !ok {
+				return
+			}
+			for _, item := range items {
+				err := deleteTodoItem(item)
+				if err != nil {
+					showError(archiveWindow, "purge the archive", err)
+					break
+				}
+			}
+			refresh()
+		}, archiveWindow)
+	})
+	if len(items) == 0 {
+		purgeAllButton.Disable()
+	}
+	header := container.NewHBox(
+		widget.NewLabel(fmt.Sprintf("Archived tasks are purged after %d days", int(archiveRetention.Hours()/24))),
+		purgeAllButton,
+	)
+
+	if len(items) == 0 {
+		return container.NewBorder(header, nil, nil, nil, widget.NewLabel("The archive is empty"))
+	}
+
+	rows := make([]fyne.CanvasObject, len(items))
+	for i, item := range items {
+		restoreButton := widget.NewButtonWithIcon("Restore", theme.ContentUndoIcon(), func(item *TodoItem) func() {
+			return func() {
+				err := restoreTodoItem(item)
+				if err != nil {
+					showError(archiveWindow, "restore the task", err)
+					return
+				}
+				if item.ListID == currentList.ID {
+					changeTodoItems(func(items []*TodoItem) []*TodoItem {
+						return insertByPosition(items, item)
+					})
+					refreshTodoList(a, w)
+				}
+				refresh()
+			}
+		}(item))
+		purgeButton := widget.NewButtonWithIcon("Purge", theme.DeleteIcon(), func(item *TodoItem) func() {
+			return func() {
+				err := deleteTodoItem(item)
+				if err != nil {
+					showError(archiveWindow, "purge the task", err)
+					return
+				}
+				refresh()
+			}
+		}(item))
+
+		rows[i] = container.NewHBox(
+			widget.NewLabel(item.Title),
+			widget.NewLabel(findTodoList(item.ListID).Name),
+			widget.NewLabel(item.ArchivedAt.Format(dueDateLayout)),
+			restoreButton,
+			purgeButton,
+		)
+	}
+	return container.NewBorder(header, nil, nil, nil, container.NewVScroll(container.NewVBox(rows...)))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GoDo starts with the desktop session through an XDG autostart entry, which
+// starts it hidden in the system tray.
+
+const autostartFile = "godo.desktop"
+
+// autostartPath returns the autostart entry in $XDG_CONFIG_HOME/autostart,
+// usually ~/.config/autostart.
+func autostartPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "autostart", autostartFile), nil
+}
+
+func autostartEnabled() bool {
+	path, err := autostartPath()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// enableAutostart writes the autostart entry for this executable. The
+// database is opened relative to the working directory, so the entry starts
+// GoDo in the current one.
+func enableAutostart() error {
+	path, err := autostartPath()
+	if err != nil {
+		return err
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	workDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	entry := fmt.Sprintf(`[Desktop Entry]
+Type=Application
+Name=GoDo
+Comment=Timed to-do lists
+Exec=%s --hidden
+Path=%s
+Terminal=false
+X-GNOME-Autostart-enabled=true
+`, quoteDesktopExec(executable), workDir)
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(entry), 0644)
+}
+
+func disableAutostart() error {
+	path, err := autostartPath()
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// quoteDesktopExec quotes an argument of an Exec key as the Desktop Entry
+// Specification requires.
+func quoteDesktopExec(arg string) string {
+	if !strings.ContainsAny(arg, " \t\n\"'\\><~|&;$*?#()`") {
+		return arg
+	}
+	escaped := strings.NewReplacer(`"`, `\"`, "`", "\\`", "$", `\$`, `\`, `\\`).Replace(arg)
+	// Backslashes are escaped once more in the string value of the key.
+	return `"` + strings.ReplaceAll(escaped, `\`, `\\`) + `"`
+}
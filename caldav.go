@@ -0,0 +1,609 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// CalDAV sync maps the tasks of one list onto the VTODO resources of one
+// collection. Each synced resource is remembered with its ETag and a hash of
+// the task as last synced, which tells remote changes (new ETag) apart from
+// local ones (new hash); a task changed on both sides is a conflict. Changes
+// on the server are found with a sync-collection REPORT (RFC 6578) when the
+// server supports it, and with a PROPFIND of all ETags otherwise.
+
+const (
+	conflictServer    = "server"
+	conflictLocal     = "local"
+	conflictDuplicate = "duplicate"
+)
+
+var conflictPolicies = []string{conflictServer, conflictLocal, conflictDuplicate}
+
+var errPreconditionFailed = errors.New("the resource was changed on the server")
+
+var errSyncTokenInvalid = errors.New("sync token rejected")
+
+type caldavClient struct {
+	collection *url.URL
+	username   string
+	password   string
+	http       *http.Client
+}
+
+func newCalDAVClient(config caldavConfig) (*caldavClient, error) {
+	collection, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, err
+	}
+	if collection.Scheme != "http" && collection.Scheme != "https" {
+		return nil, fmt.Errorf("caldav.url must be an http or https URL, not %q", config.URL)
+	}
+	if !strings.HasSuffix(collection.Path, "/") {
+		collection.Path += "/"
+	}
+	password := config.Password
+	if env := os.Getenv("GODO_CALDAV_PASSWORD"); env != "" {
+		password = env
+	}
+	return &caldavClient{
+		collection: collection,
+		username:   config.Username,
+		password:   password,
+		http:       &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (c *caldavClient) do(method, href string, body []byte, header map[string]string) (*http.Response, error) {
+	target, err := c.collection.Parse(href)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequest(method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if c.username != "" {
+		request.SetBasicAuth(c.username, c.password)
+	}
+	for key, value := range header {
+		request.Header.Set(key, value)
+	}
+	return c.http.Do(request)
+}
+
+type davMultistatus struct {
+	Responses []davResponse `xml:"DAV: response"`
+	SyncToken string        `xml:"DAV: sync-token"`
+}
+
+type davResponse struct {
+	Href      string `xml:"DAV: href"`
+	Status    string `xml:"DAV: status"`
+	Propstats []struct {
+		Status string `xml:"DAV: status"`
+		ETag   string `xml:"DAV: prop>getetag"`
+	} `xml:"DAV: propstat"`
+}
+
+// etag returns the response's ETag, or "" if the member was removed or has
+// none.
+func (r davResponse) etag() string {
+	if strings.Contains(r.Status, " 404 ") {
+		return ""
+	}
+	for _, propstat := range r.Propstats {
+		if strings.Contains(propstat.Status, " 200 ") && propstat.ETag != "" {
+			return propstat.ETag
+		}
+	}
+	return ""
+}
+
+func (c *caldavClient) multistatus(method, href, depth, body string) (*davMultistatus, int, error) {
+	response, err := c.do(method, href, []byte(body), map[string]string{
+		"Content-Type": "application/xml; charset=utf-8",
+		"Depth":        depth,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusMultiStatus {
+		io.Copy(io.Discard, response.Body)
+		return nil, response.StatusCode, fmt.Errorf("%s %s: %s", method, response.Request.URL, response.Status)
+	}
+	var result davMultistatus
+	err = xml.NewDecoder(response.Body).Decode(&result)
+	return &result, response.StatusCode, err
+}
+
+// syncCollection returns the members changed since token with their ETags,
+// the removed members, and the new sync token.
+func (c *caldavClient) syncCollection(token string) (map[string]string, []string, string, error) {
+	var body bytes.Buffer
+	body.WriteString(`<?xml version="1.0" encoding="utf-8"?><d:sync-collection xmlns:d="DAV:"><d:sync-token>`)
+	xml.EscapeText(&body, []byte(token))
+	body.WriteString(`</d:sync-token><d:sync-level>1</d:sync-level><d:prop><d:getetag/></d:prop></d:sync-collection>`)
+
+	result, status, err := c.multistatus("REPORT", "", "0", body.String())
+	if err != nil {
+		if token != "" && (status == http.StatusForbidden || status == http.StatusConflict) {
+			return nil, nil, "", errSyncTokenInvalid
+		}
+		return nil, nil, "", err
+	}
+
+	changed := map[string]string{}
+	var removed []string
+	for _, response := range result.Responses {
+		if c.isCollection(response.Href) {
+			continue
+		}
+		if etag := response.etag(); etag != "" {
+			changed[response.Href] = etag
+		} else {
+			removed = append(removed, response.Href)
+		}
+	}
+	return changed, removed, result.SyncToken, nil
+}
+
+const propfindETag = `<?xml version="1.0" encoding="utf-8"?><d:propfind xmlns:d="DAV:"><d:prop><d:getetag/></d:prop></d:propfind>`
+
+// listETags returns the ETag of every member of the collection.
+func (c *caldavClient) listETags() (map[string]string, error) {
+	result, _, err := c.multistatus("PROPFIND", "", "1", propfindETag)
+	if err != nil {
+		return nil, err
+	}
+	etags := map[string]string{}
+	for _, response := range result.Responses {
+		if etag := response.etag(); etag != "" && !c.isCollection(response.Href) {
+			etags[response.Href] = etag
+		}
+	}
+	return etags, nil
+}
+
+// etag returns the ETag of the resource at href.
+func (c *caldavClient) etag(href string) (string, error) {
+	result, _, err := c.multistatus("PROPFIND", href, "0", propfindETag)
+	if err != nil {
+		return "", err
+	}
+	for _, response := range result.Responses {
+		if etag := response.etag(); etag != "" {
+			return etag, nil
+		}
+	}
+	return "", fmt.Errorf("PROPFIND %s: no ETag", href)
+}
+
+func (c *caldavClient) isCollection(href string) bool {
+	target, err := c.collection.Parse(href)
+	return err == nil && strings.TrimSuffix(target.Path, "/") == strings.TrimSuffix(c.collection.Path, "/")
+}
+
+func (c *caldavClient) get(href string) ([]byte, string, error) {
+	response, err := c.do("GET", href, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("GET %s: %s", href, response.Status)
+	}
+	data, err := io.ReadAll(response.Body)
+	return data, response.Header.Get("ETag"), err
+}
+
+// put stores data at href if the resource still has etag, or, with etag "",
+// if it does not exist yet. With force set it is stored unconditionally. The
+// new ETag is returned if the server sends one, "" otherwise.
+func (c *caldavClient) put(href string, data []byte, etag string, force bool) (string, error) {
+	header := map[string]string{"Content-Type": "text/calendar; charset=utf-8"}
+	switch {
+	case force:
+	case etag == "":
+		header["If-None-Match"] = "*"
+	default:
+		header["If-Match"] = etag
+	}
+	response, err := c.do("PUT", href, data, header)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+	switch {
+	case response.StatusCode == http.StatusPreconditionFailed:
+		return "", errPreconditionFailed
+	case response.StatusCode >= 300:
+		return "", fmt.Errorf("PUT %s: %s", href, response.Status)
+	}
+	return response.Header.Get("ETag"), nil
+}
+
+func (c *caldavClient) delete(href string, etag string, force bool) error {
+	header := map[string]string{}
+	if !force && etag != "" {
+		header["If-Match"] = etag
+	}
+	response, err := c.do("DELETE", href, nil, header)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+	switch {
+	case response.StatusCode == http.StatusPreconditionFailed:
+		return errPreconditionFailed
+	case response.StatusCode >= 300 && response.StatusCode != http.StatusNotFound:
+		return fmt.Errorf("DELETE %s: %s", href, response.Status)
+	}
+	return nil
+}
+
+// caldavResource is what was last synced for one member of a collection.
+// Members that are not tasks, such as events, have no TaskID. Data is the
+// resource as last synced, which pushes patch so that the properties GoDo
+// does not map survive.
+type caldavResource struct {
+	Href   string
+	TaskID string
+	ETag   string
+	Hash   string
+	Data   string
+}
+
+func getCalDAVSyncToken(collection string) (string, error) {
+	var token string
+	err := db.QueryRow(`SELECT sync_token FROM caldav_collections WHERE url = ?`, collection).Scan(&token)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return token, err
+}
+
+func saveCalDAVSyncToken(collection, token string) error {
+	_, err := execWrite(`INSERT INTO caldav_collections (url, sync_token) VALUES (?, ?)
+		ON CONFLICT (url) DO UPDATE SET sync_token = excluded.sync_token`, collection, token)
+	return err
+}
+
+func getCalDAVResources(collection string) (map[string]*caldavResource, error) {
+	rows, err := db.Query(`SELECT href, task_id, etag, hash, data FROM caldav_resources WHERE collection = ?`, collection)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resources := map[string]*caldavResource{}
+	for rows.Next() {
+		resource := &caldavResource{}
+		err = rows.Scan(&resource.Href, &resource.TaskID, &resource.ETag, &resource.Hash, &resource.Data)
+		if err != nil {
+			return nil, err
+		}
+		resources[resource.Href] = resource
+	}
+	return resources, rows.Err()
+}
+
+func saveCalDAVResource(collection string, resource *caldavResource) error {
+	_, err := execWrite(`INSERT INTO caldav_resources (collection, href, task_id, etag, hash, data) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (collection, href) DO UPDATE SET task_id = excluded.task_id, etag = excluded.etag, hash = excluded.hash, data = excluded.data`,
+		collection, resource.Href, resource.TaskID, resource.ETag, resource.Hash, resource.Data)
+	return err
+}
+
+func deleteCalDAVResource(collection, href string) error {
+	_, err := execWrite(`DELETE FROM caldav_resources WHERE collection = ? AND href = ?`, collection, href)
+	return err
+}
+
+// caldavReport counts what a sync changed.
+type caldavReport struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Archived  int `json:"archived"`
+	Pushed    int `json:"pushed"`
+	Deleted   int `json:"deleted"`
+	Conflicts int `json:"conflicts"`
+}
+
+type caldavSync struct {
+	client     *caldavClient
+	collection string
+	list       *TodoList
+	policy     string
+	resources  map[string]*caldavResource
+	tasks      map[string]*TodoItem
+	report     caldavReport
+}
+
+// syncCalDAV runs one two-way sync of list with the configured collection.
+// With full set the stored sync token is ignored and all ETags compared.
+func syncCalDAV(config caldavConfig, list *TodoList, full bool) (*caldavReport, error) {
+	policy := config.Conflict
+	if policy == "" {
+		policy = conflictServer
+	}
+	valid := false
+	for _, p := range conflictPolicies {
+		valid = valid || p == policy
+	}
+	if !valid {
+		return nil, fmt.Errorf("unknown conflict policy %q, want server, local or duplicate", policy)
+	}
+
+	client, err := newCalDAVClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &caldavSync{client: client, collection: client.collection.String(), list: list, policy: policy}
+	s.resources, err = getCalDAVResources(s.collection)
+	if err != nil {
+		return nil, err
+	}
+	s.tasks = map[string]*TodoItem{}
+	active, err := getAllTodoItems()
+	if err != nil {
+		return nil, err
+	}
+	archived, err := getArchivedTodoItems()
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range append(active, archived...) {
+		s.tasks[item.ID.String()] = item
+	}
+
+	token := ""
+	if !full {
+		token, err = getCalDAVSyncToken(s.collection)
+		if err != nil {
+			return nil, err
+		}
+	}
+	changed, removed, newToken, err := client.syncCollection(token)
+	if errors.Is(err, errSyncTokenInvalid) {
+		changed, removed, newToken, err = client.syncCollection("")
+	}
+	if err != nil {
+		// The server does not support sync-collection; compare all ETags.
+		newToken = ""
+		changed, err = client.listETags()
+		if err != nil {
+			return nil, err
+		}
+		removed = nil
+		for href := range s.resources {
+			if _, ok := changed[href]; !ok {
+				removed = append(removed, href)
+			}
+		}
+	} else if token == "" {
+		// A full listing only reports what exists.
+		for href := range s.resources {
+			if _, ok := changed[href]; !ok {
+				removed = append(removed, href)
+			}
+		}
+	}
+
+	for href, etag := range changed {
+		err = s.pull(href, etag)
+		if err != nil {
+			return &s.report, err
+		}
+	}
+	for _, href := range removed {
+		err = s.pullRemoval(href)
+		if err != nil {
+			return &s.report, err
+		}
+	}
+	err = s.push()
+	if err != nil {
+		return &s.report, err
+	}
+	return &s.report, saveCalDAVSyncToken(s.collection, newToken)
+}
+
+// pull applies a created or changed server resource to the local tasks.
+func (s *caldavSync) pull(href, etag string) error {
+	resource := s.resources[href]
+	if resource != nil && resource.ETag == etag {
+		return nil
+	}
+	data, fetchedETag, err := s.client.get(href)
+	if err != nil {
+		return err
+	}
+	if fetchedETag != "" {
+		etag = fetchedETag
+	}
+	items, err := parseICalendar(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", href, err)
+	}
+	if len(items) == 0 {
+		// Not a task; remember it so it is not fetched again.
+		return s.saveResource(&caldavResource{Href: href, ETag: etag})
+	}
+	remote := items[0]
+
+	local := s.tasks[remote.ID.String()]
+	switch {
+	case local == nil:
+		remote.ListID = s.list.ID
+		err = saveTodoItem(remote)
+		if err != nil {
+			return err
+		}
+		s.tasks[remote.ID.String()] = remote
+		s.report.Created++
+		return s.saveResource(&caldavResource{Href: href, TaskID: remote.ID.String(), ETag: etag, Hash: todoItemHash(remote), Data: string(data)})
+
+	case resource != nil && todoItemHash(local) != resource.Hash:
+		s.report.Conflicts++
+		switch s.policy {
+		case conflictLocal:
+			// Keep the local task; push overwrites the server copy.
+			return s.saveResource(&caldavResource{Href: href, TaskID: local.ID.String(), ETag: etag, Hash: resource.Hash, Data: string(data)})
+		case conflictDuplicate:
+			duplicate, err := newTodoItem(local.Title, local.Duration, local.ListID)
+			if err != nil {
+				return err
+			}
+			duplicate.Priority, duplicate.DueDate, duplicate.Tags = local.Priority, local.DueDate, local.Tags
+			duplicate.Notes, duplicate.Completed, duplicate.Recurrence = local.Notes, local.Completed, local.Recurrence
+			err = saveTodoItem(duplicate)
+			if err != nil {
+				return err
+			}
+			s.tasks[duplicate.ID.String()] = duplicate
+		}
+	}
+
+	copyEditableFields(local, remote)
+	err = updateTodoItem(local)
+	if err != nil {
+		return err
+	}
+	err = updateRemainingTime(local)
+	if err != nil {
+		return err
+	}
+	s.report.Updated++
+	return s.saveResource(&caldavResource{Href: href, TaskID: local.ID.String(), ETag: etag, Hash: todoItemHash(local), Data: string(data)})
+}
+
+// pullRemoval archives the local task of a resource deleted on the server,
+// unless it was edited locally and the policy keeps local changes.
+func (s *caldavSync) pullRemoval(href string) error {
+	resource := s.resources[href]
+	if resource == nil {
+		return nil
+	}
+	err := deleteCalDAVResource(s.collection, href)
+	if err != nil {
+		return err
+	}
+	delete(s.resources, href)
+
+	local := s.tasks[resource.TaskID]
+	if local == nil || !local.ArchivedAt.IsZero() {
+		return nil
+	}
+	if todoItemHash(local) != resource.Hash {
+		s.report.Conflicts++
+		if s.policy != conflictServer {
+			// Without a resource the task is pushed again as new.
+			return nil
+		}
+	}
+	s.report.Archived++
+	return archiveTodoItem(local)
+}
+
+// push uploads tasks of the list that are new or edited since the last sync
+// and deletes the resources of tasks that were archived, deleted or moved to
+// another list locally. Edited tasks patch the resource as last synced.
+func (s *caldavSync) push() error {
+	byTask := map[string]*caldavResource{}
+	for _, resource := range s.resources {
+		if resource.TaskID != "" {
+			byTask[resource.TaskID] = resource
+		}
+	}
+
+	for id, resource := range byTask {
+		local := s.tasks[id]
+		if local != nil && local.ArchivedAt.IsZero() && local.ListID == s.list.ID {
+			continue
+		}
+		err := s.client.delete(resource.Href, resource.ETag, s.policy == conflictLocal)
+		if errors.Is(err, errPreconditionFailed) {
+			// Changed on the server since; the next sync pulls the change.
+			s.report.Conflicts++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = deleteCalDAVResource(s.collection, resource.Href)
+		if err != nil {
+			return err
+		}
+		s.report.Deleted++
+	}
+
+	for id, local := range s.tasks {
+		if local.ListID != s.list.ID || !local.ArchivedAt.IsZero() {
+			continue
+		}
+		resource := byTask[id]
+		hash := todoItemHash(local)
+		if resource != nil && resource.Hash == hash {
+			continue
+		}
+		if resource == nil {
+			resource = &caldavResource{Href: s.client.collection.Path + id + ".ics", TaskID: id}
+		}
+
+		var data bytes.Buffer
+		var err error
+		if resource.Data != "" {
+			err = patchICalendar(&data, resource.Data, local)
+		} else {
+			err = writeICalendar(&data, []*TodoItem{local})
+		}
+		if err != nil {
+			return err
+		}
+		etag, err := s.client.put(resource.Href, data.Bytes(), resource.ETag, false)
+		if errors.Is(err, errPreconditionFailed) {
+			s.report.Conflicts++
+			if s.policy == conflictServer {
+				// The next sync pulls the server copy over the local task.
+				continue
+			}
+			etag, err = s.client.put(resource.Href, data.Bytes(), resource.ETag, true)
+		}
+		if err == nil && etag == "" {
+			// Without its ETag the next sync would take the resource for one
+			// changed on the server and pull it again.
+			etag, err = s.client.etag(resource.Href)
+		}
+		if err != nil {
+			return err
+		}
+		resource.ETag = etag
+		resource.Hash = hash
+		resource.Data = data.String()
+		err = s.saveResource(resource)
+		if err != nil {
+			return err
+		}
+		s.report.Pushed++
+	}
+	return nil
+}
+
+func (s *caldavSync) saveResource(resource *caldavResource) error {
+	s.resources[resource.Href] = resource
+	return saveCalDAVResource(s.collection, resource)
+}
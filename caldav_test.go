@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"github.com/google/uuid"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+// The CalDAV tests sync with a real server. They are skipped unless
+// $GODO_TEST_CALDAV_URL names a collection the test may create calendars in,
+// each of which it deletes again. With Radicale, which with --auth-type none
+// accepts any user:
+//
+//	python3 -m radicale --storage-filesystem-folder /tmp/radicale --auth-type none
+//	GODO_TEST_CALDAV_URL=http://localhost:5232/godo/ GODO_TEST_CALDAV_USER=godo go test -tags sqlite_fts5 -run CalDAV .
+//
+// $GODO_TEST_CALDAV_PASSWORD is sent along with the user if set.
+
+// newTestCalendar creates an empty calendar for the test and returns the
+// configuration syncing with it.
+func newTestCalendar(t *testing.T) caldavConfig {
+	base := os.Getenv("GODO_TEST_CALDAV_URL")
+	if base == "" {
+		t.Skip("set GODO_TEST_CALDAV_URL to test CalDAV sync")
+	}
+	config := caldavConfig{
+		URL:      strings.TrimSuffix(base, "/") + "/godo-test-" + uuid.NewString() + "/",
+		Username: os.Getenv("GODO_TEST_CALDAV_USER"),
+		Password: os.Getenv("GODO_TEST_CALDAV_PASSWORD"),
+	}
+	client, err := newCalDAVClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := client.do("MKCALENDAR", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("MKCALENDAR %s: %s", config.URL, response.Status)
+	}
+	t.Cleanup(func() {
+		response, err := client.do("DELETE", "", nil, nil)
+		if err == nil {
+			response.Body.Close()
+		}
+	})
+	return config
+}
+
+func syncTestCalendar(t *testing.T, config caldavConfig, list *TodoList) caldavReport {
+	t.Helper()
+	report, err := syncCalDAV(config, list, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *report
+}
+
+func TestCalDAVSync(t *testing.T) {
+	config := newTestCalendar(t)
+	openTestDB(t)
+	lists, err := getTodoLists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := lists[0]
+	client, err := newCalDAVClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := addTestTodoItem(t, "Write report", list.ID)
+	review := addTestTodoItem(t, "Review changes", list.ID)
+	if got := syncTestCalendar(t, config, list); got != (caldavReport{Pushed: 2}) {
+		t.Fatalf("first sync: got %+v, want 2 pushed", got)
+	}
+	// The ETags stored by the push match the server's, so nothing is pulled.
+	if got := syncTestCalendar(t, config, list); got != (caldavReport{}) {
+		t.Fatalf("sync without changes: got %+v, want nothing done", got)
+	}
+
+	href := client.collection.Path + report.ID.String() + ".ics"
+	data, etag, err := client.get(href)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.Replace(data, []byte("SUMMARY:Write report"), []byte("SUMMARY:Write the report"), 1)
+	_, err = client.put(href, data, etag, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := syncTestCalendar(t, config, list); got != (caldavReport{Updated: 1}) {
+		t.Fatalf("sync after a server change: got %+v, want 1 updated", got)
+	}
+	items, err := findTodoItems(report.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items[0].Title != "Write the report" {
+		t.Errorf("title after pulling the change: got %q", items[0].Title)
+	}
+
+	// Changed on both sides, the server copy wins by default.
+	items[0].Title = "Write the local report"
+	err = updateTodoItem(items[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, etag, err = client.get(href)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.Replace(data, []byte("SUMMARY:Write the report"), []byte("SUMMARY:Write the remote report"), 1)
+	_, err = client.put(href, data, etag, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := syncTestCalendar(t, config, list); got != (caldavReport{Updated: 1, Conflicts: 1}) {
+		t.Fatalf("sync after changes on both sides: got %+v, want 1 updated with a conflict", got)
+	}
+	items, err = findTodoItems(report.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items[0].Title != "Write the remote report" {
+		t.Errorf("title after a conflict: got %q, want the server's", items[0].Title)
+	}
+
+	err = archiveTodoItem(items[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := syncTestCalendar(t, config, list); got != (caldavReport{Deleted: 1}) {
+		t.Fatalf("sync after archiving a task: got %+v, want 1 deleted", got)
+	}
+
+	err = client.delete(client.collection.Path+review.ID.String()+".ics", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := syncTestCalendar(t, config, list); got != (caldavReport{Archived: 1}) {
+		t.Fatalf("sync after a server deletion: got %+v, want 1 archived", got)
+	}
+}
+
+func TestCalDAVSyncKeepsServerProperties(t *testing.T) {
+	config := newTestCalendar(t)
+	openTestDB(t)
+	lists, err := getTodoLists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := lists[0]
+	client, err := newCalDAVClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item := addTestTodoItem(t, "Pay rent", list.ID)
+	if got := syncTestCalendar(t, config, list); got != (caldavReport{Pushed: 1}) {
+		t.Fatalf("first sync: got %+v, want 1 pushed", got)
+	}
+
+	// Another app adds an alarm, which GoDo has no field for.
+	href := client.collection.Path + item.ID.String() + ".ics"
+	data, etag, err := client.get(href)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alarm := "BEGIN:VALARM\r\nACTION:DISPLAY\r\nDESCRIPTION:Reminder\r\nTRIGGER:-PT15M\r\nEND:VALARM\r\n"
+	data = bytes.Replace(data, []byte("END:VTODO"), []byte(alarm+"END:VTODO"), 1)
+	_, err = client.put(href, data, etag, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := syncTestCalendar(t, config, list); got != (caldavReport{Updated: 1}) {
+		t.Fatalf("sync after adding an alarm: got %+v, want 1 updated", got)
+	}
+
+	item.Title = "Pay the rent"
+	err = updateTodoItem(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := syncTestCalendar(t, config, list); got != (caldavReport{Pushed: 1}) {
+		t.Fatalf("sync after a local change: got %+v, want 1 pushed", got)
+	}
+	data, _, err = client.get(href)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("BEGIN:VALARM")) || !bytes.Contains(data, []byte("SUMMARY:Pay the rent")) {
+		t.Errorf("pushed resource lost the alarm or the change:\n%s", data)
+	}
+
+	// A task moved to a list that is not synced leaves the collection.
+	other := &TodoList{ID: uuid.NewString(), Name: "Other", SortMode: sortByManual}
+	err = saveTodoList(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item.ListID = other.ID
+	err = updateTodoItem(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := syncTestCalendar(t, config, list); got != (caldavReport{Deleted: 1}) {
+		t.Fatalf("sync after moving the task away: got %+v, want 1 deleted", got)
+	}
+}
@@ -0,0 +1,693 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// The command line interface works directly on the database through the
+// repository functions, or through the daemon when one is running, and never
+// creates a Fyne app, so it can be used from scripts and terminals without a
+// display.
+
+const defaultDuration = "25m"
+
+// shortIDLength is how many characters of a task ID the CLI prints. Any
+// unambiguous prefix is accepted as input.
+const shortIDLength = 8
+
+type cliCommand struct {
+	usage string
+	run   func(args []string, out io.Writer) error
+}
+
+var cliCommands = map[string]cliCommand{
+	"add":       {`add "task" [duration] [--priority P1-P4] [--due YYYY-MM-DD] [--tags "a b"] [--list name] [--json]`, cliAdd},
+	"list":      {`list [--list name | --all] [--json]`, cliList},
+	"done":      {`done <id> [--json]`, cliDone},
+	"rm":        {`rm <id> [--purge] [--json]`, cliRemove},
+	"start":     {`start <id> [--json]`, cliStart},
+	"stop":      {`stop <id> [--json]`, cliStop},
+	"reset":     {`reset <id> [--json]`, cliReset},
+	"export":    {`export [--format json|csv|markdown|ical] [--list name | --all] [-o file]`, cliExport},
+	"import":    {`import <file> [--format godo|todotxt|taskwarrior|markdown|ical] [--list name] [--dry-run] [--json]`, cliImport},
+	"sessions":  {`sessions [--format timewarrior|toggl|clockify] [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--list name] [--tag tag] [--email address] [-o file|dir]`, cliSessions},
+	"caldav":    {`caldav [--full] [--conflict server|local|duplicate] [--json]`, cliCalDAV},
+	"sync":      {`sync [--json]`, cliSync},
+	"autostart": {`autostart [on|off]`, cliAutostart},
+	"daemon":    {`daemon [--http 127.0.0.1:8731]`, cliDaemon},
+}
+
+var cliCommandOrder = []string{"add", "list", "done", "rm", "start", "stop", "reset", "export", "import", "sessions", "caldav", "sync", "autostart", "daemon"}
+
+var errUsage = errors.New("usage")
+
+// runCLI runs the subcommand in args and returns the process exit code.
+func runCLI(args []string) int {
+	name := args[0]
+	command, ok := cliCommands[name]
+	if !ok {
+		printCLIUsage(os.Stderr)
+		if name == "help" || name == "-h" || name == "--help" {
+			return 0
+		}
+		return 2
+	}
+
+	// Commands keep working without a vault or git history that fails to
+	// open; their changes then only reach the database.
+	err := openMirrors(currentConfig)
+	if err != nil {
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Fprintln(os.Stderr, "godo: warning:", line)
+		}
+	}
+
+	err = command.run(args[1:], os.Stdout)
+	if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, "usage: godo", command.usage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "godo:", err)
+		return 1
+	}
+	return 0
+}
+
+func printCLIUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: godo <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Without a command the GUI is started, with --hidden in the system tray only.")
+	fmt.Fprintln(w, "Commands:")
+	for _, name := range cliCommandOrder {
+		fmt.Fprintln(w, "  godo", cliCommands[name].usage)
+	}
+}
+
+// parseCLIArgs parses flags that may appear before, between or after the
+// positional arguments, which the flag package alone does not allow.
+func parseCLIArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func newFlagSet(name string) (*flag.FlagSet, *bool) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	jsonOutput := fs.Bool("json", false, "print JSON")
+	return fs, jsonOutput
+}
+
+func cliAdd(args []string, out io.Writer) error {
+	fs, jsonOutput := newFlagSet("add")
+	priority := fs.String("priority", priorityLabel(defaultPriority), "priority")
+	due := fs.String("due", "", "due date")
+	tags := fs.String("tags", "", "tags")
+	listName := fs.String("list", "", "list name")
+	positional, err := parseCLIArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) < 1 || len(positional) > 2 || positional[0] == "" {
+		return errUsage
+	}
+
+	duration := newTaskDuration()
+	if len(positional) == 2 {
+		duration = positional[1]
+	}
+
+	list, err := findTodoListByName(*listName)
+	if err != nil {
+		return err
+	}
+
+	item, err := newTodoItem(positional[0], duration, list.ID)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", duration, err)
+	}
+	item.Priority = parsePriority(strings.ToUpper(*priority))
+	if priorityLabel(item.Priority) != strings.ToUpper(*priority) {
+		return fmt.Errorf("invalid priority %q, want P1-P%d", *priority, len(priorityLabels))
+	}
+	item.Tags = parseTags(*tags)
+	if *due != "" {
+		item.DueDate, err = time.ParseInLocation(dueDateLayout, *due, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid due date %q: %w", *due, err)
+		}
+	}
+
+	item, err = addTodoItem(item)
+	if err != nil {
+		return err
+	}
+	return printTodoItems(out, []*TodoItem{item}, *jsonOutput)
+}
+
+func cliList(args []string, out io.Writer) error {
+	fs, jsonOutput := newFlagSet("list")
+	listName := fs.String("list", "", "list name")
+	all := fs.Bool("all", false, "show all lists")
+	positional, err := parseCLIArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return errUsage
+	}
+
+	var items []*TodoItem
+	if *all {
+		items, err = loadAllTodoItems()
+	} else {
+		var list *TodoList
+		list, err = findTodoListByName(*listName)
+		if err != nil {
+			return err
+		}
+		items, err = loadTodoItems(list.ID)
+		if err == nil {
+			sortTodoItems(items, list.SortMode)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return printTodoItems(out, items, *jsonOutput)
+}
+
+func cliDone(args []string, out io.Writer) error {
+	fs, jsonOutput := newFlagSet("done")
+	item, err := parseCLIItem(fs, args)
+	if err != nil {
+		return err
+	}
+
+	err = completeTodoItem(item)
+	if err != nil {
+		return err
+	}
+	return printTodoItems(out, []*TodoItem{item}, *jsonOutput)
+}
+
+func cliRemove(args []string, out io.Writer) error {
+	fs, jsonOutput := newFlagSet("rm")
+	purge := fs.Bool("purge", false, "delete permanently instead of archiving")
+	item, err := parseCLIItem(fs, args)
+	if err != nil {
+		return err
+	}
+
+	err = removeTodoItem(item, *purge)
+	if err != nil {
+		return err
+	}
+	return printTodoItems(out, []*TodoItem{item}, *jsonOutput)
+}
+
+// cliStart starts the task's timer in the daemon if one is running. Otherwise
+// it runs the timer in the foreground until it finishes or the process is
+// interrupted, saving the remaining time either way.
+func cliStart(args []string, out io.Writer) error {
+	fs, jsonOutput := newFlagSet("start")
+	item, err := parseCLIItem(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if daemon != nil {
+		err = startTimer(item)
+		if err != nil {
+			return err
+		}
+		return printTodoItems(out, []*TodoItem{item}, *jsonOutput)
+	}
+
+	finished := make(chan struct{})
+	todoItemChanged = func(changed *TodoItem) {
+		if !*jsonOutput {
+			fmt.Fprintf(out, "\r%s  %s", formatTime(changed.RemainingTime), changed.Title)
+		}
+		if !changed.Running {
+			close(finished)
+		}
+	}
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupted)
+
+	item.StartTimer()
+	todoItemChanged(item)
+
+	select {
+	case <-finished:
+	case <-interrupted:
+		err = item.StopTimer()
+		if err != nil {
+			return err
+		}
+	}
+	if !*jsonOutput {
+		fmt.Fprintln(out)
+	}
+	return printTodoItems(out, []*TodoItem{item}, *jsonOutput)
+}
+
+func cliStop(args []string, out io.Writer) error {
+	fs, jsonOutput := newFlagSet("stop")
+	item, err := parseCLIItem(fs, args)
+	if err != nil {
+		return err
+	}
+
+	// Without a daemon no timer can be running outside a foreground
+	// "godo start", so there is nothing to stop.
+	err = stopTimer(item)
+	if err != nil {
+		return err
+	}
+	return printTodoItems(out, []*TodoItem{item}, *jsonOutput)
+}
+
+func cliReset(args []string, out io.Writer) error {
+	fs, jsonOutput := newFlagSet("reset")
+	item, err := parseCLIItem(fs, args)
+	if err != nil {
+		return err
+	}
+
+	err = resetTimer(item)
+	if err != nil {
+		return err
+	}
+	return printTodoItems(out, []*TodoItem{item}, *jsonOutput)
+}
+
+// cliExport writes a list, or all lists, to stdout or a file. Without
+// --format the format is taken from the file extension, defaulting to JSON.
+func cliExport(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	formatName := fs.String("format", "", "json, csv, markdown or ical")
+	listName := fs.String("list", "", "list name")
+	all := fs.Bool("all", false, "export all lists")
+	output := fs.String("o", "", "output file")
+	positional, err := parseCLIArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return errUsage
+	}
+
+	format := exportJSON
+	if *formatName == "" && filepath.Ext(*output) != "" {
+		*formatName = filepath.Ext(*output)[1:]
+	}
+	if *formatName != "" {
+		format, err = parseExportFormat(*formatName)
+		if err != nil {
+			return err
+		}
+	}
+
+	list, err := findTodoListByName(*listName)
+	if err != nil {
+		return err
+	}
+	lists, items, err := exportScope(list, *all, format)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		return exportTodoItems(out, format, lists, items)
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	err = exportTodoItems(file, format, lists, items)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// cliSessions exports the recorded timer sessions. Both ends of the date
+// range are inclusive. With -o naming a directory, such as
+// ~/.timewarrior/data, Timewarrior sessions are merged into its monthly data
+// files.
+func cliSessions(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("sessions", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	formatName := fs.String("format", string(sessionTimewarrior), "timewarrior, toggl or clockify")
+	fromDate := fs.String("from", "", "first day")
+	toDate := fs.String("to", "", "last day")
+	listName := fs.String("list", "", "list name")
+	tag := fs.String("tag", "", "tag")
+	email := fs.String("email", "", "email address for the CSV formats")
+	output := fs.String("o", "", "output file or Timewarrior data directory")
+	positional, err := parseCLIArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return errUsage
+	}
+
+	format, err := parseSessionFormat(*formatName)
+	if err != nil {
+		return err
+	}
+	var from, to time.Time
+	if *fromDate != "" {
+		from, err = time.ParseInLocation(dueDateLayout, *fromDate, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --from date %q, want YYYY-MM-DD", *fromDate)
+		}
+	}
+	if *toDate != "" {
+		to, err = time.ParseInLocation(dueDateLayout, *toDate, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --to date %q, want YYYY-MM-DD", *toDate)
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	listID := ""
+	if *listName != "" {
+		list, err := findTodoListByName(*listName)
+		if err != nil {
+			return err
+		}
+		listID = list.ID
+	}
+
+	sessions, err := getTimerSessions(from, to, listID)
+	if err != nil {
+		return err
+	}
+	if *tag != "" {
+		sessions = filterSessionsByTag(sessions, *tag)
+	}
+
+	if *output == "" {
+		return exportTimerSessions(out, format, sessions, *email)
+	}
+	if info, err := os.Stat(*output); err == nil && info.IsDir() {
+		if format != sessionTimewarrior {
+			return fmt.Errorf("%s is a directory", *output)
+		}
+		return writeTimewarriorFiles(*output, sessions)
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	err = exportTimerSessions(file, format, sessions, *email)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// cliImport imports tasks from a file, or from stdin with "-", skipping tasks
+// that already exist. With --dry-run it only prints what would be imported.
+func cliImport(args []string, out io.Writer) error {
+	fs, jsonOutput := newFlagSet("import")
+	formatName := fs.String("format", "", "godo, todotxt, taskwarrior, markdown or ical")
+	listName := fs.String("list", "", "list name")
+	dryRun := fs.Bool("dry-run", false, "only print the tasks that would be imported")
+	positional, err := parseCLIArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errUsage
+	}
+
+	var data []byte
+	if positional[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(positional[0])
+	}
+	if err != nil {
+		return err
+	}
+	format := detectImportFormat(positional[0], data)
+	if *formatName != "" {
+		format, err = parseImportFormat(*formatName)
+		if err != nil {
+			return err
+		}
+	}
+
+	list, err := findTodoListByName(*listName)
+	if err != nil {
+		return err
+	}
+	lists, items, err := parseImport(bytes.NewReader(data), format, list.ID)
+	if err != nil {
+		return err
+	}
+	duplicates, err := importDuplicates(items)
+	if err != nil {
+		return err
+	}
+
+	var imported []*TodoItem
+	if *dryRun {
+		for i, item := range items {
+			if !duplicates[i] {
+				imported = append(imported, item)
+			}
+		}
+	} else {
+		imported, err = saveImportedTodoItems(lists, items, duplicates)
+		if err != nil {
+			return err
+		}
+	}
+	if !*jsonOutput {
+		fmt.Fprintf(out, "%d new tasks, %d duplicates skipped\n", len(imported), len(items)-len(imported))
+	}
+	return printTodoItems(out, imported, *jsonOutput)
+}
+
+// cliCalDAV syncs the list configured in the [caldav] section of the config
+// file with its CalDAV collection.
+func cliCalDAV(args []string, out io.Writer) error {
+	fs, jsonOutput := newFlagSet("caldav")
+	full := fs.Bool("full", false, "compare all ETags instead of using the sync token")
+	conflict := fs.String("conflict", "", "conflict policy")
+	positional, err := parseCLIArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return errUsage
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if config.CalDAV.URL == "" {
+		path, _ := configPath()
+		return fmt.Errorf("no CalDAV collection configured, set url in the [caldav] section of %s", path)
+	}
+	if *conflict != "" {
+		config.CalDAV.Conflict = *conflict
+	}
+	list, err := findTodoListByName(config.CalDAV.List)
+	if err != nil {
+		return err
+	}
+
+	report, err := syncCalDAV(config.CalDAV, list, *full)
+	if report != nil && *jsonOutput {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		encodeErr := encoder.Encode(report)
+		if err == nil {
+			err = encodeErr
+		}
+	} else if report != nil {
+		fmt.Fprintf(out, "%s: %d created, %d updated, %d archived, %d pushed, %d deleted, %d conflicts\n",
+			list.Name, report.Created, report.Updated, report.Archived, report.Pushed, report.Deleted, report.Conflicts)
+	}
+	return err
+}
+
+// cliSync merges the git history configured in the [git] section of the
+// config file with its remote.
+func cliSync(args []string, out io.Writer) error {
+	fs, jsonOutput := newFlagSet("sync")
+	positional, err := parseCLIArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return errUsage
+	}
+	if gitStore == nil && currentConfig.Git.Path != "" {
+		return fmt.Errorf("git history %s could not be opened", currentConfig.Git.Path)
+	}
+	if gitStore == nil {
+		path, _ := configPath()
+		return fmt.Errorf("no git history configured, set path and remote in the [git] section of %s", path)
+	}
+
+	report, err := gitStore.sync()
+	if err != nil {
+		return err
+	}
+	if *jsonOutput {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	pushed := "nothing to push"
+	if report.Pushed {
+		pushed = "pushed to " + gitStore.remote
+	}
+	fmt.Fprintf(out, "%d tasks pulled, %d conflicting fields kept local, %s\n", report.Pulled, report.Conflicts, pushed)
+	return nil
+}
+
+// cliAutostart turns starting GoDo hidden with the desktop session on or
+// off, or tells whether it is on.
+func cliAutostart(args []string, out io.Writer) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	if len(args) == 1 {
+		var err error
+		switch args[0] {
+		case "on":
+			err = enableAutostart()
+		case "off":
+			err = disableAutostart()
+		default:
+			return errUsage
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	path, err := autostartPath()
+	if err != nil {
+		return err
+	}
+	if autostartEnabled() {
+		fmt.Fprintln(out, "autostart on:", path)
+	} else {
+		fmt.Fprintln(out, "autostart off")
+	}
+	return nil
+}
+
+// parseCLIItem parses the flags of a command taking a single task ID and
+// looks the task up.
+func parseCLIItem(fs *flag.FlagSet, args []string) (*TodoItem, error) {
+	positional, err := parseCLIArgs(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	if len(positional) != 1 {
+		return nil, errUsage
+	}
+	return findTodoItemByPrefix(positional[0])
+}
+
+func findTodoItemByPrefix(prefix string) (*TodoItem, error) {
+	prefix = strings.ToLower(prefix)
+	if prefix == "" {
+		return nil, errUsage
+	}
+	items, err := findTodoItems(prefix)
+	if err != nil {
+		return nil, err
+	}
+	switch len(items) {
+	case 0:
+		return nil, fmt.Errorf("no task with ID %s", prefix)
+	case 1:
+		return items[0], nil
+	default:
+		return nil, fmt.Errorf("ID %s is ambiguous, it matches %d tasks", prefix, len(items))
+	}
+}
+
+// findTodoListByName returns the list called name, or the first list if name
+// is empty.
+func findTodoListByName(name string) (*TodoList, error) {
+	lists, err := getTodoLists()
+	if err != nil {
+		return nil, err
+	}
+	todoLists = lists
+	if name == "" {
+		return lists[0], nil
+	}
+	for _, list := range lists {
+		if strings.EqualFold(list.Name, name) {
+			return list, nil
+		}
+	}
+	return nil, fmt.Errorf("no list named %q", name)
+}
+
+func printTodoItems(out io.Writer, items []*TodoItem, jsonOutput bool) error {
+	if jsonOutput {
+		if items == nil {
+			items = []*TodoItem{}
+		}
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(items)
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, item := range items {
+		done := "[ ]"
+		if item.Completed {
+			done = "[x]"
+		}
+		due := ""
+		if !item.DueDate.IsZero() {
+			due = item.DueDate.Format(dueDateLayout)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s/%s\t%s\t%s\n",
+			item.ID.String()[:shortIDLength], done, priorityLabel(item.Priority), item.Title,
+			formatTime(item.RemainingTime), item.Duration, due, tagsLabel(item.Tags))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"github.com/BurntSushi/toml"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// godoConfig is read from config.toml in the user's config directory, or
+// from the file named by $GODO_CONFIG. A missing file means all defaults.
+type godoConfig struct {
+	CalDAV caldavConfig `toml:"caldav,omitempty"`
+	Vault  vaultConfig  `toml:"vault,omitempty"`
+	Git    gitConfig    `toml:"git,omitempty"`
+	Window windowConfig `toml:"window,omitempty"`
+	// Appearance, Timer and Database are changed in the settings window.
+	Appearance appearanceConfig `toml:"appearance,omitempty"`
+	Timer      timerConfig      `toml:"timer,omitempty"`
+	Database   databaseConfig   `toml:"database,omitempty"`
+	// Shortcuts maps keyboard shortcut actions to key combinations such as
+	// "Ctrl+Shift+N", overriding the defaults.
+	Shortcuts map[string]string `toml:"shortcuts,omitempty"`
+}
+
+type caldavConfig struct {
+	// URL is the CalDAV collection, e.g.
+	// http://localhost:5232/user/tasks/ for Radicale or
+	// https://cloud.example.com/remote.php/dav/calendars/user/tasks/ for
+	// Nextcloud.
+	URL      string `toml:"url,omitempty"`
+	Username string `toml:"username,omitempty"`
+	// Password may be left out and given as $GODO_CALDAV_PASSWORD instead.
+	Password string `toml:"password,omitempty"`
+	// List is the name of the GoDo list synced with the collection, the first
+	// list if empty.
+	List string `toml:"list,omitempty"`
+	// Conflict is the conflict policy: "server", "local" or "duplicate".
+	Conflict string `toml:"conflict,omitempty"`
+}
+
+type vaultConfig struct {
+	// Path is the folder of the Markdown vault, which is off while empty. A
+	// leading ~ stands for the home directory.
+	Path string `toml:"path,omitempty"`
+}
+
+type gitConfig struct {
+	// Path is the git working tree that records every change to the tasks,
+	// which is off while empty. A leading ~ stands for the home directory.
+	Path string `toml:"path,omitempty"`
+	// Remote is the URL or path of the repository "godo sync" pulls from and
+	// pushes to, e.g. a bare repository on a shared drive.
+	Remote string `toml:"remote,omitempty"`
+	// Branch defaults to "main".
+	Branch string `toml:"branch,omitempty"`
+}
+
+// closeQuits is the window close setting that quits instead of hiding the
+// window to the system tray.
+const closeQuits = "quit"
+
+type windowConfig struct {
+	// Close is what closing the main window does: "tray", the default, hides
+	// it to the system tray while timers keep running, "quit" stops the
+	// timers and quits.
+	Close string `toml:"close,omitempty"`
+	// Width and Height are the size the main window opens with, 400x200 if
+	// left out.
+	Width  float32 `toml:"width,omitzero"`
+	Height float32 `toml:"height,omitzero"`
+}
+
+// Themes of the appearance settings.
+const (
+	themeSystem = "system"
+	themeDark   = "dark"
+	themeLight  = "light"
+)
+
+type appearanceConfig struct {
+	// Theme is "dark", "light" or "system", the default, which follows the
+	// desktop.
+	Theme string `toml:"theme,omitempty"`
+	// TextSize is the size of text in points, the theme's if left out.
+	TextSize float32 `toml:"text_size,omitzero"`
+	// Accent is the "#rrggbb" color of buttons, selections and links, the
+	// theme's blue if empty.
+	Accent string `toml:"accent,omitempty"`
+	// HighContrast uses black and white with a strong accent instead of the
+	// theme's greys.
+	HighContrast bool `toml:"high_contrast,omitempty"`
+}
+
+type timerConfig struct {
+	// Duration is the timer of new tasks, "25m" if empty.
+	Duration string `toml:"duration,omitempty"`
+	// Presets are the durations offered for new tasks.
+	Presets []string `toml:"presets,omitempty"`
+	// Sound is the WAV file played when a timer finishes, sounds/finished.wav
+	// if empty.
+	Sound string `toml:"sound,omitempty"`
+	Mute  bool   `toml:"mute,omitempty"`
+	// Notify shows a desktop notification when a timer finishes.
+	Notify bool `toml:"notify,omitempty"`
+}
+
+type databaseConfig struct {
+	// Path is the SQLite database, todos.db in the working directory if
+	// empty. A leading ~ stands for the home directory.
+	Path string `toml:"path,omitempty"`
+}
+
+// currentConfig is the configuration in use, replaced when the settings are
+// saved.
+var currentConfig = &godoConfig{}
+
+func configPath() (string, error) {
+	if path := os.Getenv("GODO_CONFIG"); path != "" {
+		return path, nil
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "godo", "config.toml"), nil
+}
+
+func loadConfig() (*godoConfig, error) {
+	config := &godoConfig{}
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	_, err = toml.DecodeFile(path, config)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return config, nil
+}
+
+// saveConfig writes config to the config file, creating its directory if
+// needed. The comments of the file and the lines of settings that kept their
+// value stay as the user wrote them.
+func saveConfig(config *godoConfig) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	encoder := toml.NewEncoder(&buf)
+	encoder.Indent = ""
+	err = encoder.Encode(config)
+	if err != nil {
+		return err
+	}
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.WriteFile(path, []byte(mergeTOML(string(existing), buf.String())), 0644)
+}
+
+// tomlLine is a line of a TOML file, or the lines of one key's value.
+type tomlLine struct {
+	// table is the table the line is in, "" before the first header.
+	table  string
+	header bool
+	// key is set on the lines of a key's value.
+	key  string
+	text []string
+}
+
+func parseTOMLLines(content string) []tomlLine {
+	content = strings.TrimSuffix(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	if content == "" {
+		return nil
+	}
+	lines := strings.Split(content, "\n")
+	var result []tomlLine
+	table := ""
+	for i := 0; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		switch {
+		case trimmed == "" || trimmed[0] == '#':
+			result = append(result, tomlLine{table: table, text: lines[i : i+1]})
+		case trimmed[0] == '[':
+			name, _, _ := strings.Cut(trimmed, "]")
+			table = strings.ReplaceAll(strings.Trim(name, "[ "), `"`, "")
+			result = append(result, tomlLine{table: table, header: true, text: lines[i : i+1]})
+		default:
+			key, value, _ := strings.Cut(trimmed, "=")
+			start := i
+			// Arrays and inline tables may go on over several lines.
+			for depth := tomlDepth(value); depth > 0 && i+1 < len(lines); {
+				i++
+				depth += tomlDepth(lines[i])
+			}
+			result = append(result, tomlLine{table: table, key: strings.Trim(strings.TrimSpace(key), `"'`), text: lines[start : i+1]})
+		}
+	}
+	return result
+}
+
+// tomlDepth returns how many more brackets and braces s opens than it closes,
+// outside of strings and comments.
+func tomlDepth(s string) int {
+	depth := 0
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if r == '\\' && quote == '"' {
+				escaped = true
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return depth
+		case r == '[' || r == '{':
+			depth++
+		case r == ']' || r == '}':
+			depth--
+		}
+	}
+	return depth
+}
+
+// sameTOMLValue reports whether two lines give their keys the same value.
+func sameTOMLValue(a, b tomlLine) bool {
+	var aValues, bValues map[string]any
+	_, err := toml.Decode(strings.Join(a.text, "\n"), &aValues)
+	if err != nil {
+		return false
+	}
+	_, err = toml.Decode(strings.Join(b.text, "\n"), &bValues)
+	return err == nil && reflect.DeepEqual(aValues[a.key], bValues[b.key])
+}
+
+// mergeTOML returns existing, a TOML file, changed to hold the keys of
+// encoded. Keys missing from encoded are removed, those with another value
+// replaced and new ones added to the end of their table. Comments and blank
+// lines are kept.
+func mergeTOML(existing, encoded string) string {
+	type keyID struct{ table, key string }
+	updated := map[keyID]tomlLine{}
+	for _, line := range parseTOMLLines(encoded) {
+		if line.key != "" {
+			updated[keyID{line.table, line.key}] = line
+		}
+	}
+
+	var out []string
+	written := map[keyID]bool{}
+	// end is where keys added to a table go in out.
+	end := map[string]int{"": 0}
+	for _, line := range parseTOMLLines(existing) {
+		id := keyID{line.table, line.key}
+		switch {
+		case line.header:
+			out = append(out, line.text...)
+			end[line.table] = len(out)
+		case line.key == "":
+			out = append(out, line.text...)
+		default:
+			next, ok := updated[id]
+			if !ok || written[id] {
+				continue
+			}
+			written[id] = true
+			if sameTOMLValue(line, next) {
+				out = append(out, line.text...)
+			} else {
+				out = append(out, next.text...)
+			}
+			end[line.table] = len(out)
+		}
+	}
+
+	inserted := map[int][]string{}
+	var appended []string
+	lastLine := func() string {
+		switch {
+		case len(appended) > 0:
+			return appended[len(appended)-1]
+		case len(out) > 0:
+			return out[len(out)-1]
+		}
+		return ""
+	}
+	for _, line := range parseTOMLLines(encoded) {
+		at, present := end[line.table]
+		switch {
+		case present && line.key != "" && !written[keyID{line.table, line.key}]:
+			inserted[at] = append(inserted[at], line.text...)
+		case !present && (line.header || line.key != ""):
+			if line.header && lastLine() != "" {
+				appended = append(appended, "")
+			}
+			appended = append(appended, line.text...)
+		}
+	}
+
+	var result []string
+	for i := 0; i <= len(out); i++ {
+		result = append(result, inserted[i]...)
+		if i < len(out) {
+			result = append(result, out[i])
+		}
+	}
+	result = append(result, appended...)
+	if len(result) == 0 {
+		return ""
+	}
+	return strings.Join(result, "\n") + "\n"
+}
+
+// expandHome replaces a leading ~ in path with the user's home directory.
+func expandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveConfigKeepsComments(t *testing.T) {
+	existing := `# GoDo settings
+
+[timer]
+# The timer of new tasks.
+duration = "25m" # a pomodoro
+presets = [
+  "25m", # short
+  "50m",
+]
+mute = true
+
+[database]
+path = "~/todos.db"
+`
+	config := &godoConfig{
+		Timer:    timerConfig{Duration: "25m", Presets: []string{"25m", "50m"}, Notify: true},
+		Database: databaseConfig{Path: "~/work.db"},
+		Window:   windowConfig{Close: closeQuits},
+	}
+	path := filepath.Join(t.TempDir(), "config.toml")
+	t.Setenv("GODO_CONFIG", path)
+	err := os.WriteFile(path, []byte(existing), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = saveConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# GoDo settings
+
+[timer]
+# The timer of new tasks.
+duration = "25m" # a pomodoro
+presets = [
+  "25m", # short
+  "50m",
+]
+notify = true
+
+[database]
+path = "~/work.db"
+
+[window]
+close = "quit"
+`
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want {
+		t.Errorf("got\n%s\nwant\n%s", data, want)
+	}
+	decoded, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Database.Path != "~/work.db" || !decoded.Timer.Notify || decoded.Timer.Mute || len(decoded.Timer.Presets) != 2 {
+		t.Errorf("the merged file reads as %+v", decoded)
+	}
+}
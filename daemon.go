@@ -0,0 +1,633 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// The daemon owns the task timers so that they keep running while no window
+// is open. It speaks JSON-RPC 2.0 over a Unix domain socket, one JSON object
+// per line. Clients that call "subscribe" receive "event" notifications on
+// that connection for every timer change.
+
+type rpcRequest struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// Error codes defined by JSON-RPC 2.0, plus one for failed operations.
+const (
+	rpcParseError     = -32700
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000
+)
+
+type rpcListParams struct {
+	ListID string `json:"list_id,omitempty"`
+	All    bool   `json:"all,omitempty"`
+}
+
+type rpcAddParams struct {
+	Title    string     `json:"title"`
+	Duration string     `json:"duration,omitempty"`
+	Priority int        `json:"priority,omitempty"`
+	DueDate  *time.Time `json:"due_date,omitempty"`
+	Tags     []string   `json:"tags,omitempty"`
+	ListID   string     `json:"list_id,omitempty"`
+}
+
+type rpcItemParams struct {
+	ID    string `json:"id"`
+	Purge bool   `json:"purge,omitempty"`
+}
+
+// rpcUpdateParams changes the fields that are set and leaves the others alone.
+type rpcUpdateParams struct {
+	ID        string     `json:"id"`
+	Title     *string    `json:"title,omitempty"`
+	Duration  *string    `json:"duration,omitempty"`
+	Completed *bool      `json:"completed,omitempty"`
+	Priority  *int       `json:"priority,omitempty"`
+	DueDate   *time.Time `json:"due_date,omitempty"`
+	Tags      *[]string  `json:"tags,omitempty"`
+	Notes     *string    `json:"notes,omitempty"`
+}
+
+type daemonEvent struct {
+	Type string    `json:"type"`
+	Item *TodoItem `json:"item"`
+}
+
+// Event types sent to subscribers.
+const (
+	eventAdded    = "added"
+	eventStarted  = "started"
+	eventTick     = "tick"
+	eventStopped  = "stopped"
+	eventReset    = "reset"
+	eventFinished = "finished"
+	eventDone     = "done"
+	eventUpdated  = "updated"
+	eventRemoved  = "removed"
+)
+
+type daemonServer struct {
+	lock sync.Mutex
+	// timers are the tasks whose timers the daemon runs. Everything else
+	// about a task is read from the database for every call.
+	timers map[uuid.UUID]*TodoItem
+
+	subscribersLock sync.Mutex
+	subscribers     map[chan daemonEvent]bool
+}
+
+func newDaemonServer() *daemonServer {
+	return &daemonServer{
+		timers:      map[uuid.UUID]*TodoItem{},
+		subscribers: map[chan daemonEvent]bool{},
+	}
+}
+
+type daemonConn struct {
+	conn    net.Conn
+	lock    sync.Mutex
+	encoder *json.Encoder
+	events  chan daemonEvent
+}
+
+func (c *daemonConn) send(v interface{}) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.encoder.Encode(v)
+}
+
+// daemonSocketPath returns where the daemon listens, preferring the per-user
+// runtime directory.
+func daemonSocketPath() string {
+	if path := os.Getenv("GODO_SOCKET"); path != "" {
+		return path
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "godo.sock")
+	}
+	return filepath.Join(privateSocketDir(), "godo.sock")
+}
+
+// privateSocketDir is where the socket goes without a runtime directory. The
+// temp directory is shared, so the socket gets a directory of its own that
+// other users cannot enter, even before the socket's mode is set.
+func privateSocketDir() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("godo-%d", os.Getuid()))
+}
+
+// makePrivateDir creates dir for this user only, or makes sure an existing
+// one is a real directory nobody else can enter.
+func makePrivateDir(dir string) error {
+	err := os.Mkdir(dir, 0700)
+	if err != nil && !errors.Is(err, os.ErrExist) {
+		return err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	// Chmod fails on a directory that another user created.
+	return os.Chmod(dir, 0700)
+}
+
+func cliDaemon(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	httpAddr := fs.String("http", "", "also serve the REST API on this loopback address")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errUsage
+	}
+	if daemon != nil {
+		return errors.New("daemon is already running")
+	}
+
+	path := daemonSocketPath()
+	if filepath.Dir(path) == privateSocketDir() {
+		err = makePrivateDir(filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+	}
+	// A socket file nobody answers on is left over from a crashed daemon.
+	_ = os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(path, 0600)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	server := newDaemonServer()
+	todoItemChanged = server.timerChanged
+
+	if vaultStore != nil {
+		err = vaultStore.watch(func(items []*TodoItem) {
+			for _, item := range items {
+				server.broadcast(eventUpdated, item)
+			}
+		})
+		if err != nil {
+			listener.Close()
+			return err
+		}
+	}
+
+	if *httpAddr != "" {
+		httpServer, err := startRESTServer(server, *httpAddr, out)
+		if err != nil {
+			listener.Close()
+			return err
+		}
+		defer httpServer.Close()
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		listener.Close()
+	}()
+
+	fmt.Fprintln(out, "godo daemon listening on", path)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			break
+		}
+		go server.serve(conn)
+	}
+
+	server.stopAll()
+	_ = os.Remove(path)
+	return nil
+}
+
+func (s *daemonServer) serve(conn net.Conn) {
+	c := &daemonConn{conn: conn, encoder: json.NewEncoder(conn)}
+	defer func() {
+		if c.events != nil {
+			s.unsubscribe(c.events)
+		}
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var request rpcRequest
+		response := rpcResponse{JSONRPC: "2.0"}
+
+		err := json.Unmarshal(scanner.Bytes(), &request)
+		if err != nil {
+			response.Error = &rpcError{Code: rpcParseError, Message: err.Error()}
+		} else {
+			response.ID = request.ID
+			result, err := s.handle(c, request)
+			if err != nil {
+				response.Error = toRPCError(err)
+			} else {
+				response.Result, err = json.Marshal(result)
+				if err != nil {
+					response.Error = toRPCError(err)
+				}
+			}
+		}
+
+		// Requests without an ID are notifications and get no response.
+		if request.ID == nil && response.Error == nil {
+			continue
+		}
+		err = c.send(response)
+		if err != nil {
+			return
+		}
+	}
+}
+
+func toRPCError(err error) *rpcError {
+	var e *rpcError
+	if errors.As(err, &e) {
+		return e
+	}
+	return &rpcError{Code: rpcServerError, Message: err.Error()}
+}
+
+func (s *daemonServer) handle(c *daemonConn, request rpcRequest) (interface{}, error) {
+	switch request.Method {
+	case "list":
+		var params rpcListParams
+		err := decodeParams(request.Params, &params)
+		if err != nil {
+			return nil, err
+		}
+		return s.list(params)
+	case "add":
+		var params rpcAddParams
+		err := decodeParams(request.Params, &params)
+		if err != nil {
+			return nil, err
+		}
+		return s.add(params)
+	case "update":
+		var params rpcUpdateParams
+		err := decodeParams(request.Params, &params)
+		if err != nil {
+			return nil, err
+		}
+		return s.update(params)
+	case "start", "stop", "reset", "done", "remove":
+		var params rpcItemParams
+		err := decodeParams(request.Params, &params)
+		if err != nil {
+			return nil, err
+		}
+		return s.itemAction(request.Method, params)
+	case "subscribe":
+		if c.events == nil {
+			c.events = s.subscribe()
+			go c.forwardEvents()
+		}
+		return true, nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: "unknown method " + request.Method}
+}
+
+func decodeParams(raw json.RawMessage, params interface{}) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	err := json.Unmarshal(raw, params)
+	if err != nil {
+		return &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// list reads tasks from the database and overlays the live state of the
+// timers the daemon is running.
+func (s *daemonServer) list(params rpcListParams) ([]*TodoItem, error) {
+	var items []*TodoItem
+	var err error
+	if params.All {
+		items, err = getAllTodoItems()
+	} else {
+		listID := params.ListID
+		if listID == "" {
+			listID = defaultListID
+		}
+		items, err = getTodoItems(listID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, item := range items {
+		if timer, ok := s.timers[item.ID]; ok {
+			live := timer.snapshot()
+			item.RemainingTime = live.RemainingTime
+			item.Running = live.Running
+		}
+	}
+	if items == nil {
+		items = []*TodoItem{}
+	}
+	return items, nil
+}
+
+// get returns a task from the database with the live state of its timer.
+func (s *daemonServer) get(id string) (*TodoItem, error) {
+	items, err := findTodoItems(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) != 1 {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: "no task with ID " + id}
+	}
+	item := items[0]
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if timer, ok := s.timers[item.ID]; ok {
+		live := timer.snapshot()
+		item.RemainingTime = live.RemainingTime
+		item.Running = live.Running
+	}
+	return item, nil
+}
+
+func (s *daemonServer) add(params rpcAddParams) (*TodoItem, error) {
+	if params.Title == "" {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: "title is required"}
+	}
+	if params.Duration == "" {
+		params.Duration = newTaskDuration()
+	}
+	if params.ListID == "" {
+		params.ListID = defaultListID
+	}
+
+	item, err := newTodoItem(params.Title, params.Duration, params.ListID)
+	if err != nil {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+	if params.Priority != 0 {
+		item.Priority = params.Priority
+	}
+	if params.DueDate != nil {
+		item.DueDate = *params.DueDate
+	}
+	item.Tags = params.Tags
+
+	err = saveTodoItem(item)
+	if err != nil {
+		return nil, err
+	}
+	s.broadcast(eventAdded, item)
+	return item, nil
+}
+
+// load reads a task from the database. Every call loads the task again, as
+// the GUI and the CLI change tasks in the database directly.
+func (s *daemonServer) load(rawID string) (*TodoItem, error) {
+	id, err := uuid.Parse(rawID)
+	if err != nil {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+	items, err := findTodoItems(id.String())
+	if err != nil {
+		return nil, err
+	}
+	if len(items) != 1 {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: "no task with ID " + id.String()}
+	}
+	return items[0], nil
+}
+
+// timer returns the task whose timer the daemon runs for item, brought up to
+// date with item, or item itself if no timer runs for it. The caller must
+// hold s.lock.
+func (s *daemonServer) timer(item *TodoItem) *TodoItem {
+	timer, ok := s.timers[item.ID]
+	if !ok {
+		return item
+	}
+	timerLock.Lock()
+	defer timerLock.Unlock()
+	if !timer.Running {
+		// The timer finished, which stored its remaining time in item.
+		delete(s.timers, item.ID)
+		return item
+	}
+	copyEditableFields(timer, item)
+	timer.ListID = item.ListID
+	timer.Position = item.Position
+	timer.ArchivedAt = item.ArchivedAt
+	return timer
+}
+
+func (s *daemonServer) update(params rpcUpdateParams) (*TodoItem, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	item, err := s.load(params.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.Title != nil {
+		item.Title = *params.Title
+	}
+	if params.Duration != nil {
+		_, err = time.ParseDuration(*params.Duration)
+		if err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+		item.Duration = *params.Duration
+	}
+	if params.Completed != nil {
+		item.Completed = *params.Completed
+	}
+	if params.Priority != nil {
+		item.Priority = *params.Priority
+	}
+	if params.DueDate != nil {
+		item.DueDate = *params.DueDate
+	}
+	if params.Tags != nil {
+		item.Tags = *params.Tags
+	}
+	if params.Notes != nil {
+		item.Notes = *params.Notes
+	}
+
+	err = updateTodoItem(item)
+	if err != nil {
+		return nil, err
+	}
+	item = s.timer(item)
+	s.broadcast(eventUpdated, item)
+	return item.snapshot(), nil
+}
+
+func (s *daemonServer) itemAction(action string, params rpcItemParams) (*TodoItem, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	item, err := s.load(params.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch action {
+	case "start":
+		item = s.timer(item)
+		item.StartTimer()
+		s.timers[item.ID] = item
+		s.broadcast(eventStarted, item)
+	case "stop":
+		item = s.timer(item)
+		err = item.StopTimer()
+		delete(s.timers, item.ID)
+		s.broadcast(eventStopped, item)
+	case "reset":
+		item = s.timer(item)
+		err = item.ResetTimer()
+		delete(s.timers, item.ID)
+	case "done":
+		item.Completed = true
+		err = updateCompleted(item)
+		item = s.timer(item)
+		s.broadcast(eventDone, item)
+	case "remove":
+		item = s.timer(item)
+		logTimerError(item.StopTimer())
+		delete(s.timers, item.ID)
+		if params.Purge {
+			err = deleteTodoItem(item)
+		} else {
+			err = archiveTodoItem(item)
+		}
+		s.broadcast(eventRemoved, item)
+	}
+	return item.snapshot(), err
+}
+
+// timerChanged is installed as todoItemChanged while the daemon runs.
+func (s *daemonServer) timerChanged(item *TodoItem) {
+	item = item.snapshot()
+	switch {
+	case item.Running:
+		s.broadcast(eventTick, item)
+	case item.RemainingTime <= 0:
+		s.broadcast(eventFinished, item)
+	default:
+		s.broadcast(eventReset, item)
+	}
+}
+
+// subscribe returns a channel receiving every event until unsubscribe is
+// called. Events are dropped for subscribers that fall too far behind.
+func (s *daemonServer) subscribe() chan daemonEvent {
+	events := make(chan daemonEvent, 64)
+	s.subscribersLock.Lock()
+	s.subscribers[events] = true
+	s.subscribersLock.Unlock()
+	return events
+}
+
+func (s *daemonServer) unsubscribe(events chan daemonEvent) {
+	s.subscribersLock.Lock()
+	if s.subscribers[events] {
+		delete(s.subscribers, events)
+		close(events)
+	}
+	s.subscribersLock.Unlock()
+}
+
+func (s *daemonServer) broadcast(eventType string, item *TodoItem) {
+	// Subscribers read the item on other goroutines, so give them a copy.
+	event := daemonEvent{Type: eventType, Item: item.snapshot()}
+
+	s.subscribersLock.Lock()
+	defer s.subscribersLock.Unlock()
+	for events := range s.subscribers {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+func (c *daemonConn) forwardEvents() {
+	for event := range c.events {
+		params, err := json.Marshal(event)
+		if err != nil {
+			slog.Error("daemon event", "error", err)
+			continue
+		}
+		err = c.send(rpcResponse{JSONRPC: "2.0", Method: "event", Params: params})
+		if err != nil {
+			return
+		}
+	}
+}
+
+// stopAll stops every running timer, saving its remaining time.
+func (s *daemonServer) stopAll() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, item := range s.timers {
+		logTimerError(item.StopTimer())
+	}
+}
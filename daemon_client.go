@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// daemon is the connection to a running godo daemon, or nil if there is none.
+// While it is set the GUI and CLI are thin clients: adding tasks and timer
+// control go through the daemon instead of this process.
+var daemon *daemonClient
+
+type daemonClient struct {
+	lock    sync.Mutex
+	conn    net.Conn
+	encoder *json.Encoder
+	scanner *bufio.Scanner
+	nextID  int
+}
+
+func dialDaemon() (*daemonClient, error) {
+	conn, err := net.DialTimeout("unix", daemonSocketPath(), time.Second)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return &daemonClient{conn: conn, encoder: json.NewEncoder(conn), scanner: scanner}, nil
+}
+
+func (c *daemonClient) Close() error {
+	return c.conn.Close()
+}
+
+// call sends a request and decodes the result into result, which may be nil.
+func (c *daemonClient) call(method string, params interface{}, result interface{}) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	c.nextID++
+	id := json.RawMessage(strconv.Itoa(c.nextID))
+	err = c.encoder.Encode(rpcRequest{JSONRPC: "2.0", ID: &id, Method: method, Params: rawParams})
+	if err != nil {
+		return err
+	}
+
+	for c.scanner.Scan() {
+		var response rpcResponse
+		err = json.Unmarshal(c.scanner.Bytes(), &response)
+		if err != nil {
+			return err
+		}
+		if response.Method != "" {
+			// A notification, not the response we are waiting for.
+			continue
+		}
+		if response.Error != nil {
+			return response.Error
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(response.Result, result)
+	}
+	if err = c.scanner.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// subscribe calls handle for every event the daemon sends until the
+// connection is lost, and then lost. It uses a connection of its own.
+func (c *daemonClient) subscribe(handle func(event daemonEvent), lost func()) error {
+	sub, err := dialDaemon()
+	if err != nil {
+		return err
+	}
+	err = sub.call("subscribe", nil, nil)
+	if err != nil {
+		sub.Close()
+		return err
+	}
+
+	go func() {
+		defer sub.Close()
+		for sub.scanner.Scan() {
+			var notification rpcResponse
+			err := json.Unmarshal(sub.scanner.Bytes(), &notification)
+			if err != nil || notification.Method != "event" {
+				continue
+			}
+			var event daemonEvent
+			err = json.Unmarshal(notification.Params, &event)
+			if err != nil {
+				slog.Warn("invalid daemon event", "error", err)
+				continue
+			}
+			handle(event)
+		}
+		slog.Warn("lost connection to the godo daemon")
+		lost()
+	}()
+	return nil
+}
+
+// loadTodoItems returns the tasks of a list, with live timer state when a
+// daemon is running.
+func loadTodoItems(listID string) ([]*TodoItem, error) {
+	if daemon == nil {
+		return getTodoItems(listID)
+	}
+	var items []*TodoItem
+	err := daemon.call("list", rpcListParams{ListID: listID}, &items)
+	return items, err
+}
+
+func loadAllTodoItems() ([]*TodoItem, error) {
+	if daemon == nil {
+		return getAllTodoItems()
+	}
+	var items []*TodoItem
+	err := daemon.call("list", rpcListParams{All: true}, &items)
+	return items, err
+}
+
+// addTodoItem saves a new task and returns it as stored.
+func addTodoItem(item *TodoItem) (*TodoItem, error) {
+	if daemon == nil {
+		return item, saveTodoItem(item)
+	}
+	params := rpcAddParams{
+		Title:    item.Title,
+		Duration: item.Duration,
+		Priority: item.Priority,
+		DueDate:  optionalTime(item.DueDate),
+		Tags:     item.Tags,
+		ListID:   item.ListID,
+	}
+	var added TodoItem
+	err := daemon.call("add", params, &added)
+	return &added, err
+}
+
+// startTimer, stopTimer and resetTimer control a task's timer, in the daemon
+// if one is running and in this process otherwise.
+func startTimer(item *TodoItem) error {
+	return timerAction("start", item, func() error {
+		item.StartTimer()
+		return nil
+	})
+}
+
+func stopTimer(item *TodoItem) error {
+	return timerAction("stop", item, item.StopTimer)
+}
+
+func resetTimer(item *TodoItem) error {
+	return timerAction("reset", item, item.ResetTimer)
+}
+
+func timerAction(method string, item *TodoItem, local func() error) error {
+	if daemon == nil {
+		return local()
+	}
+	var updated TodoItem
+	err := daemon.call(method, rpcItemParams{ID: item.ID.String()}, &updated)
+	if err != nil {
+		return err
+	}
+	item.RemainingTime = updated.RemainingTime
+	item.Running = updated.Running
+	todoItemChanged(item)
+	return nil
+}
+
+func completeTodoItem(item *TodoItem) error {
+	item.Completed = true
+	if daemon == nil {
+		return updateCompleted(item)
+	}
+	return daemon.call("done", rpcItemParams{ID: item.ID.String()}, nil)
+}
+
+func removeTodoItem(item *TodoItem, purge bool) error {
+	if daemon == nil {
+		if purge {
+			return deleteTodoItem(item)
+		}
+		return archiveTodoItem(item)
+	}
+	return daemon.call("remove", rpcItemParams{ID: item.ID.String(), Purge: purge}, nil)
+}
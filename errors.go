@@ -0,0 +1,26 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"log/slog"
+)
+
+// Failures the user caused or has to know about are shown in an error dialog
+// on the window they happened in and logged. Failures in the background,
+// such as those of a finishing timer, are only logged. The GUI never exits on
+// an error, so running timers always get to store their remaining time.
+
+// showError logs err and shows it in a dialog on w. action is what failed,
+// such as "save the task".
+func showError(w fyne.Window, action string, err error) {
+	slog.Error("could not "+action, "error", err)
+	dialog.ShowError(fmt.Errorf("could not %s: %w", action, err), w)
+}
+
+// showInvalidInput tells the user what is wrong with what they entered.
+func showInvalidInput(w fyne.Window, message string) {
+	dialog.ShowError(errors.New(message), w)
+}
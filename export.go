@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type exportFormat string
+
+const (
+	exportJSON     exportFormat = "json"
+	exportCSV      exportFormat = "csv"
+	exportMarkdown exportFormat = "markdown"
+	exportICal     exportFormat = "ical"
+)
+
+var exportFormats = []exportFormat{exportJSON, exportCSV, exportMarkdown, exportICal}
+
+var exportExtensions = map[exportFormat]string{
+	exportJSON:     ".json",
+	exportCSV:      ".csv",
+	exportMarkdown: ".md",
+	exportICal:     ".ics",
+}
+
+var exportFormatLabels = map[exportFormat]string{
+	exportJSON:     "JSON",
+	exportCSV:      "CSV",
+	exportMarkdown: "Markdown",
+	exportICal:     "iCalendar",
+}
+
+// exportDocument is the JSON export format. It holds every stored field of
+// the exported lists and of their tasks, archived ones included, so importing
+// it with the godo import format restores them without losing data.
+type exportDocument struct {
+	Version    int         `json:"version"`
+	ExportedAt time.Time   `json:"exported_at"`
+	Lists      []*TodoList `json:"lists"`
+	Tasks      []*TodoItem `json:"tasks"`
+}
+
+const exportVersion = 1
+
+func parseExportFormat(s string) (exportFormat, error) {
+	for _, format := range exportFormats {
+		if string(format) == strings.ToLower(s) || exportExtensions[format] == "."+strings.ToLower(s) {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("unknown export format %q, want json, csv, markdown or ical", s)
+}
+
+// exportTodoItems writes items, which belong to lists, to out in format.
+func exportTodoItems(out io.Writer, format exportFormat, lists []*TodoList, items []*TodoItem) error {
+	switch format {
+	case exportJSON:
+		return exportAsJSON(out, lists, items)
+	case exportCSV:
+		return exportAsCSV(out, lists, items)
+	case exportMarkdown:
+		return exportAsMarkdown(out, lists, items)
+	case exportICal:
+		return writeICalendar(out, items)
+	}
+	return fmt.Errorf("unknown export format %q", format)
+}
+
+func exportAsJSON(out io.Writer, lists []*TodoList, items []*TodoItem) error {
+	if items == nil {
+		items = []*TodoItem{}
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(exportDocument{
+		Version:    exportVersion,
+		ExportedAt: time.Now(),
+		Lists:      lists,
+		Tasks:      items,
+	})
+}
+
+var csvHeader = []string{"id", "list", "title", "duration", "remaining", "completed", "priority", "due_date", "created_at", "tags", "notes"}
+
+func exportAsCSV(out io.Writer, lists []*TodoList, items []*TodoItem) error {
+	names := listNames(lists)
+	writer := csv.NewWriter(out)
+	err := writer.Write(csvHeader)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		due := ""
+		if !item.DueDate.IsZero() {
+			due = item.DueDate.Format(dueDateLayout)
+		}
+		err = writer.Write([]string{
+			item.ID.String(),
+			names[item.ListID],
+			item.Title,
+			item.Duration,
+			formatTime(item.RemainingTime),
+			strconv.FormatBool(item.Completed),
+			priorityLabel(item.Priority),
+			due,
+			formatStoredTime(item.CreatedAt),
+			formatTags(item.Tags),
+			item.Notes,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// exportAsMarkdown writes a checklist per list, one "- [ ] Task (25m)" line
+// per task.
+func exportAsMarkdown(out io.Writer, lists []*TodoList, items []*TodoItem) error {
+	for i, list := range lists {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintf(out, "## %s\n\n", list.Name)
+		for _, item := range items {
+			if item.ListID != list.ID {
+				continue
+			}
+			_, err := fmt.Fprintln(out, markdownChecklistLine(item))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func markdownChecklistLine(item *TodoItem) string {
+	mark := " "
+	if item.Completed {
+		mark = "x"
+	}
+	line := fmt.Sprintf("- [%s] %s (%s)", mark, item.Title, item.Duration)
+	if len(item.Tags) > 0 {
+		line += " " + tagsLabel(item.Tags)
+	}
+	return line
+}
+
+func listNames(lists []*TodoList) map[string]string {
+	names := make(map[string]string, len(lists))
+	for _, list := range lists {
+		names[list.ID] = list.Name
+	}
+	return names
+}
+
+// exportScope returns the lists and tasks to export in format: the current
+// list, or every list when all is set. The JSON export is a backup, so it
+// also holds the archived tasks of those lists.
+func exportScope(list *TodoList, all bool, format exportFormat) ([]*TodoList, []*TodoItem, error) {
+	lists := []*TodoList{list}
+	var items []*TodoItem
+	var err error
+	if all {
+		lists, err = getTodoLists()
+		if err != nil {
+			return nil, nil, err
+		}
+		items, err = loadAllTodoItems()
+	} else {
+		items, err = loadTodoItems(list.ID)
+		sortTodoItems(items, list.SortMode)
+	}
+	if err != nil || format != exportJSON {
+		return lists, items, err
+	}
+
+	archived, err := getArchivedTodoItems()
+	if err != nil {
+		return nil, nil, err
+	}
+	exported := map[string]bool{}
+	for _, list := range lists {
+		exported[list.ID] = true
+	}
+	for _, item := range archived {
+		if exported[item.ListID] {
+			items = append(items, item)
+		}
+	}
+	return lists, items, nil
+}
+
+func exportFileName(list *TodoList, all bool, format exportFormat) string {
+	name := "godo-all"
+	if !all {
+		name = "godo-" + strings.ToLower(strings.ReplaceAll(list.Name, " ", "-"))
+	}
+	return name + exportExtensions[format]
+}
+
+// makeExportButton returns a toolbar button opening a menu of export choices.
+func makeExportButton(w fyne.Window) fyne.CanvasObject {
+	var button *widget.Button
+	button = widget.NewButtonWithIcon("", theme.DownloadIcon(), func() {
+		var items []*fyne.MenuItem
+		for _, all := range []bool{false, true} {
+			for _, format := range exportFormats {
+				label := fmt.Sprintf("Export list as %s…", exportFormatLabels[format])
+				if all {
+					label = fmt.Sprintf("Export all lists as %s…", exportFormatLabels[format])
+				}
+				all, format := all, format
+				items = append(items, fyne.NewMenuItem(label, func() {
+					showExportDialog(w, format, all)
+				}))
+			}
+			if !all {
+				items = append(items, fyne.NewMenuItemSeparator())
+			}
+		}
+		widget.ShowPopUpMenuAtRelativePosition(fyne.NewMenu("Export", items...), w.Canvas(),
+			fyne.NewPos(0, button.Size().Height), button)
+	})
+	button.Importance = widget.LowImportance
+	return button
+}
+
+func showExportDialog(w fyne.Window, format exportFormat, all bool) {
+	list := currentList
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			showError(w, "choose the file", err)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+
+		lists, items, err := exportScope(list, all, format)
+		if err == nil {
+			err = exportTodoItems(writer, format, lists, items)
+		}
+		if err != nil {
+			showError(w, "export the tasks", err)
+		}
+	}, w)
+	saveDialog.SetFileName(exportFileName(list, all, format))
+	saveDialog.Show()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"github.com/google/uuid"
+	"path/filepath"
+	"testing"
+)
+
+func TestJSONExportRestoresArchivedTasksAndLists(t *testing.T) {
+	openTestDB(t)
+	list := &TodoList{ID: uuid.NewString(), Name: "Errands", SortMode: sortByManual, Color: "#ff8800"}
+	err := saveTodoList(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addTestTodoItem(t, "Buy milk", list.ID)
+	archived := addTestTodoItem(t, "Buy milk", list.ID)
+	err = archiveTodoItem(archived)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	lists, items, err := exportScope(list, false, exportJSON)
+	if err == nil {
+		err = exportTodoItems(&out, exportJSON, lists, items)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = openDB(filepath.Join(t.TempDir(), "restored.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lists, items, err = parseImport(&out, importGoDo, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	duplicates, err := importDuplicates(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = saveImportedTodoItems(lists, items, duplicates)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := getTodoLists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var color string
+	for _, l := range restored {
+		if l.ID == list.ID {
+			color = l.Color
+		}
+	}
+	if color != list.Color {
+		t.Errorf("restored list color %q, want %q", color, list.Color)
+	}
+	live, err := getTodoItems(list.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gone, err := getArchivedTodoItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(live) != 1 || len(gone) != 1 || gone[0].ID != archived.ID {
+		t.Errorf("restored %d tasks and %d archived ones, want 1 and 1", len(live), len(gone))
+	}
+}
@@ -0,0 +1,579 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The git history writes every task and list as one JSON line, sorted by ID,
+// to a git working tree and commits after each change. "godo sync" fetches
+// the configured remote, merges the two histories task by task and pushes the
+// result. A task changed on both sides is merged field by field; where both
+// changed the same field, the local value wins.
+//
+// Changes are committed by a goroutine of their own, so that the GUI does not
+// wait for git. Another GoDo process, such as "godo sync", may use the same
+// working tree; git commands that find its index locked are retried.
+
+const (
+	gitTasksFile = "tasks.jsonl"
+	gitListsFile = "lists.jsonl"
+)
+
+var gitStore *gitHistory
+
+type gitHistory struct {
+	dir    string
+	remote string
+	branch string
+	// identity is passed to git when the user has not configured one.
+	identity []string
+
+	lock sync.Mutex
+	// syncing is set while sync applies merged tasks, which must not be
+	// committed one by one. It is read without the lock, which sync holds.
+	syncing atomic.Bool
+
+	// changed wakes the goroutine committing changes until done is closed,
+	// after which it closes stopped.
+	changed chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+	closed  atomic.Bool
+}
+
+// gitLockRetries is how many times a git command that found the index locked
+// is retried, waiting 100ms, 200ms, 400ms and so on in between.
+const gitLockRetries = 6
+
+// openGitHistory creates the working tree if needed, commits the current
+// state and from then on commits every change to the database.
+func openGitHistory(config gitConfig) (*gitHistory, error) {
+	dir, err := expandHome(config.Path)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	h := &gitHistory{
+		dir:     dir,
+		remote:  config.Remote,
+		branch:  config.Branch,
+		changed: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if h.branch == "" {
+		h.branch = "main"
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		_, err = h.git("init", "-q")
+		if err != nil {
+			return nil, err
+		}
+		_, err = h.git("symbolic-ref", "HEAD", "refs/heads/"+h.branch)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if email, _ := h.git("config", "user.email"); email == "" {
+		h.identity = []string{"-c", "user.name=GoDo", "-c", "user.email=godo@localhost"}
+	}
+
+	err = h.commit("Record tasks")
+	if err != nil {
+		return nil, err
+	}
+
+	go h.commitChanges()
+	previous := todosChanged
+	todosChanged = func() {
+		previous()
+		h.databaseChanged()
+	}
+	return h, nil
+}
+
+// close commits what is left to commit and stops committing changes.
+func (h *gitHistory) close() error {
+	if h.closed.Swap(true) {
+		return nil
+	}
+	close(h.done)
+	<-h.stopped
+	return h.commit("Update tasks")
+}
+
+func (h *gitHistory) git(args ...string) (string, error) {
+	output, stderr, err := h.runGit(args)
+	for i := 0; i < gitLockRetries && err != nil && strings.Contains(stderr, "index.lock"); i++ {
+		first, _, _ := strings.Cut(stderr, "\n")
+		slog.Warn("git index locked, retrying", "error", first)
+		time.Sleep(time.Duration(100<<i) * time.Millisecond)
+		output, stderr, err = h.runGit(args)
+	}
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, stderr)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (h *gitHistory) runGit(args []string) ([]byte, string, error) {
+	cmd := exec.Command("git", append(append([]string{}, h.identity...), args...)...)
+	cmd.Dir = h.dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	return output, strings.TrimSpace(stderr.String()), err
+}
+
+// databaseChanged has the change committed in the background. Changes made
+// before the commit starts are committed together.
+func (h *gitHistory) databaseChanged() {
+	if h.syncing.Load() || h.closed.Load() {
+		return
+	}
+	select {
+	case h.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (h *gitHistory) commitChanges() {
+	defer close(h.stopped)
+	for {
+		select {
+		case <-h.changed:
+			err := h.commit("Update tasks")
+			if err != nil {
+				slog.Error("git history", "error", err)
+			}
+		case <-h.done:
+			return
+		}
+	}
+}
+
+func (h *gitHistory) commit(message string) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.commitLocked(message)
+}
+
+// commitLocked writes the export and commits it if anything changed.
+func (h *gitHistory) commitLocked(message string) error {
+	if _, err := os.Stat(filepath.Join(h.dir, ".git", "MERGE_HEAD")); err == nil {
+		// "godo sync" in another process is merging; it commits the tasks
+		// as they are once the merge is done.
+		return nil
+	}
+	err := h.writeExport()
+	if err != nil {
+		return err
+	}
+	status, err := h.git("status", "--porcelain", "--", gitTasksFile, gitListsFile)
+	if err != nil || status == "" {
+		return err
+	}
+	_, err = h.git("add", "--", gitTasksFile, gitListsFile)
+	if err != nil {
+		return err
+	}
+	_, err = h.git("commit", "-q", "-m", message)
+	return err
+}
+
+// writeExport writes all tasks, archived ones included, and all lists.
+func (h *gitHistory) writeExport() error {
+	active, err := getAllTodoItems()
+	if err != nil {
+		return err
+	}
+	archived, err := getArchivedTodoItems()
+	if err != nil {
+		return err
+	}
+	lists, err := getTodoLists()
+	if err != nil {
+		return err
+	}
+
+	var tasks, listLines []string
+	for _, item := range append(active, archived...) {
+		line, err := historyTaskLine(item)
+		if err != nil {
+			return err
+		}
+		tasks = append(tasks, line)
+	}
+	for _, list := range lists {
+		line, err := json.Marshal(list)
+		if err != nil {
+			return err
+		}
+		listLines = append(listLines, string(line))
+	}
+
+	err = writeJSONLines(filepath.Join(h.dir, gitTasksFile), tasks)
+	if err != nil {
+		return err
+	}
+	return writeJSONLines(filepath.Join(h.dir, gitListsFile), listLines)
+}
+
+// timerFields are the task fields left out of the history. A timer's state
+// belongs to the device running it; merging it would rewind or stop timers
+// elsewhere, and every tick would be a change to commit.
+var timerFields = []string{"remaining_time", "running"}
+
+// historyTaskLine returns the JSON line of item in the history.
+func historyTaskLine(item *TodoItem) (string, error) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return "", err
+	}
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return "", err
+	}
+	for _, field := range timerFields {
+		delete(fields, field)
+	}
+	data, err = json.Marshal(fields)
+	return string(data), err
+}
+
+// writeJSONLines writes lines sorted by their "id" field, which keeps diffs
+// small and the file stable between machines.
+func writeJSONLines(path string, lines []string) error {
+	ids := make(map[string]string, len(lines))
+	for _, line := range lines {
+		ids[line] = jsonLineID(line)
+	}
+	sort.Slice(lines, func(i, j int) bool { return ids[lines[i]] < ids[lines[j]] })
+
+	content := strings.Join(lines, "\n")
+	if content != "" {
+		content += "\n"
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+func jsonLineID(line string) string {
+	var v struct {
+		ID string `json:"id"`
+	}
+	_ = json.Unmarshal([]byte(line), &v)
+	return v.ID
+}
+
+// gitSyncReport says what a sync did.
+type gitSyncReport struct {
+	Pulled    int  `json:"pulled"`
+	Conflicts int  `json:"conflicts"`
+	Pushed    bool `json:"pushed"`
+}
+
+// sync pulls the remote branch, merges it with the local history, applies
+// the result to the database and pushes it.
+func (h *gitHistory) sync() (*gitSyncReport, error) {
+	if h.remote == "" {
+		return nil, errors.New("no remote configured for the git history")
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.syncing.Store(true)
+	defer h.syncing.Store(false)
+
+	report := &gitSyncReport{}
+	err := h.commitLocked("Update tasks")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = h.git("fetch", "-q", h.remote, h.branch)
+	if err != nil && strings.Contains(err.Error(), "couldn't find remote ref") {
+		// The remote is empty; publish the local history.
+		report.Pushed = true
+		return report, h.push()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ours, err := h.git("rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	theirs, err := h.git("rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return nil, err
+	}
+	// Histories started on two machines have no merge base.
+	base, _ := h.git("merge-base", "HEAD", "FETCH_HEAD")
+
+	switch base {
+	case theirs:
+		// Nothing new on the remote.
+	case ours:
+		_, err = h.git("merge", "-q", "--ff-only", "FETCH_HEAD")
+		if err != nil {
+			return nil, err
+		}
+		report.Pulled, err = h.applyFiles(
+			h.readFile(ours, gitTasksFile), h.readFile(theirs, gitTasksFile),
+			h.readFile(ours, gitListsFile), h.readFile(theirs, gitListsFile))
+		if err != nil {
+			return nil, err
+		}
+		// Tasks the database stores differently, e.g. with more precise
+		// times, are committed on top.
+		err = h.commitLocked("Update tasks")
+	default:
+		tasks, conflicts, err := mergeJSONLines(h.readFile(base, gitTasksFile), h.readFile(ours, gitTasksFile), h.readFile(theirs, gitTasksFile))
+		if err != nil {
+			return nil, err
+		}
+		lists, listConflicts, err := mergeJSONLines(h.readFile(base, gitListsFile), h.readFile(ours, gitListsFile), h.readFile(theirs, gitListsFile))
+		if err != nil {
+			return nil, err
+		}
+		report.Conflicts = conflicts + listConflicts
+		report.Pulled, err = h.applyFiles(h.readFile(ours, gitTasksFile), tasks, h.readFile(ours, gitListsFile), lists)
+		if err != nil {
+			return nil, err
+		}
+
+		args := []string{"merge", "-q", "--no-commit", "-s", "ours"}
+		if base == "" {
+			args = append(args, "--allow-unrelated-histories")
+		}
+		_, err = h.git(append(args, "FETCH_HEAD")...)
+		if err != nil {
+			return nil, err
+		}
+		err = h.writeExport()
+		if err == nil {
+			_, err = h.git("add", "--", gitTasksFile, gitListsFile)
+		}
+		if err == nil {
+			_, err = h.git("commit", "-q", "-m", "Merge tasks from "+h.remote)
+		}
+		if err != nil {
+			// Leave no merge behind that would keep changes from being
+			// committed.
+			_, _ = h.git("merge", "--abort")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if base != ours || ours != theirs {
+		report.Pushed = true
+		err = h.push()
+	}
+	return report, err
+}
+
+func (h *gitHistory) push() error {
+	_, err := h.git("push", "-q", h.remote, "HEAD:refs/heads/"+h.branch)
+	return err
+}
+
+// readFile returns file as of rev. Files missing in rev, or a missing merge
+// base, read as empty.
+func (h *gitHistory) readFile(rev, file string) string {
+	if rev == "" {
+		return ""
+	}
+	content, err := h.git("show", rev+":"+file)
+	if err != nil {
+		return ""
+	}
+	return content
+}
+
+// applyFiles stores the tasks and lists that differ between the old and new
+// exports in the database and returns how many tasks changed.
+func (h *gitHistory) applyFiles(oldTasks, newTasks, oldLists, newLists string) (int, error) {
+	oldListLines, newListLines := jsonLinesByID(oldLists), jsonLinesByID(newLists)
+	for id, line := range newListLines {
+		if oldListLines[id] == line {
+			continue
+		}
+		list := &TodoList{}
+		err := json.Unmarshal([]byte(line), list)
+		if err != nil {
+			return 0, err
+		}
+		err = replaceTodoList(list)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	changed := 0
+	oldTaskLines, newTaskLines := jsonLinesByID(oldTasks), jsonLinesByID(newTasks)
+	for id, line := range newTaskLines {
+		if oldTaskLines[id] == line {
+			continue
+		}
+		item := &TodoItem{}
+		err := json.Unmarshal([]byte(line), item)
+		if err != nil {
+			return changed, err
+		}
+		// Tasks known here keep the state of their timer.
+		existing, err := findTodoItems(id)
+		if err != nil {
+			return changed, err
+		}
+		if len(existing) == 1 {
+			item.RemainingTime = existing[0].RemainingTime
+		}
+		err = replaceTodoItem(item)
+		if err != nil {
+			return changed, err
+		}
+		changed++
+	}
+	for id, line := range oldTaskLines {
+		if _, ok := newTaskLines[id]; ok {
+			continue
+		}
+		item := &TodoItem{}
+		err := json.Unmarshal([]byte(line), item)
+		if err != nil {
+			return changed, err
+		}
+		err = deleteTodoItem(item)
+		if err != nil {
+			return changed, err
+		}
+		changed++
+	}
+	return changed, nil
+}
+
+func jsonLinesByID(content string) map[string]string {
+	lines := map[string]string{}
+	for _, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines[jsonLineID(line)] = line
+		}
+	}
+	return lines
+}
+
+// mergeJSONLines merges the JSON lines of two versions of a file with their
+// common base, object by object keyed by "id". It returns the merged content
+// and how many fields were changed differently on both sides, in which case
+// ours wins.
+func mergeJSONLines(base, ours, theirs string) (string, int, error) {
+	baseLines, ourLines, theirLines := jsonLinesByID(base), jsonLinesByID(ours), jsonLinesByID(theirs)
+	ids := map[string]bool{}
+	for id := range ourLines {
+		ids[id] = true
+	}
+	for id := range theirLines {
+		ids[id] = true
+	}
+
+	var merged []string
+	conflicts := 0
+	for id := range ids {
+		b, o, t := baseLines[id], ourLines[id], theirLines[id]
+		switch {
+		case o == t, t == b:
+			if o != "" {
+				merged = append(merged, o)
+			}
+		case o == b:
+			if t != "" {
+				merged = append(merged, t)
+			}
+		case o == "":
+			// Deleted here but changed there: keep the change.
+			merged = append(merged, t)
+		case t == "":
+			merged = append(merged, o)
+		default:
+			line, n, err := mergeJSONFields(b, o, t)
+			if err != nil {
+				return "", 0, err
+			}
+			merged = append(merged, line)
+			conflicts += n
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool { return jsonLineID(merged[i]) < jsonLineID(merged[j]) })
+
+	content := strings.Join(merged, "\n")
+	if content != "" {
+		content += "\n"
+	}
+	return content, conflicts, nil
+}
+
+func mergeJSONFields(base, ours, theirs string) (string, int, error) {
+	var b, o, t map[string]json.RawMessage
+	for _, v := range []struct {
+		line   string
+		fields *map[string]json.RawMessage
+	}{{base, &b}, {ours, &o}, {theirs, &t}} {
+		*v.fields = map[string]json.RawMessage{}
+		if v.line == "" {
+			continue
+		}
+		err := json.Unmarshal([]byte(v.line), v.fields)
+		if err != nil {
+			return "", 0, err
+		}
+		// Histories written before the timer fields were left out have them.
+		for _, field := range timerFields {
+			delete(*v.fields, field)
+		}
+	}
+
+	conflicts := 0
+	keys := map[string]bool{}
+	for key := range o {
+		keys[key] = true
+	}
+	for key := range t {
+		keys[key] = true
+	}
+	for key := range keys {
+		ourValue, theirValue := string(o[key]), string(t[key])
+		switch {
+		case ourValue == theirValue, theirValue == string(b[key]):
+		case ourValue == string(b[key]):
+			if theirValue == "" {
+				delete(o, key)
+			} else {
+				o[key] = t[key]
+			}
+		default:
+			conflicts++
+		}
+	}
+
+	line, err := json.Marshal(o)
+	return string(line), conflicts, err
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useTestMachine opens the database at dbPath and the git history in dir,
+// as GoDo on one machine syncing with remote would. The history of the
+// machine used before must be closed.
+func useTestMachine(t *testing.T, dbPath, dir, remote string) *gitHistory {
+	t.Helper()
+	if db != nil {
+		_ = db.Close()
+	}
+	err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the history of this machine is committed to.
+	todosChanged = func() {}
+	h, err := openGitHistory(gitConfig{Path: dir, Remote: remote})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestGitHistorySync(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	tmp := t.TempDir()
+	remote := filepath.Join(tmp, "remote.git")
+	err := exec.Command("git", "init", "-q", "--bare", remote).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := todosChanged
+	t.Cleanup(func() {
+		todosChanged = previous
+		_ = db.Close()
+		db = nil
+	})
+	laptop, desktop := filepath.Join(tmp, "laptop.db"), filepath.Join(tmp, "desktop.db")
+
+	h := useTestMachine(t, laptop, filepath.Join(tmp, "laptop"), remote)
+	lists, err := getTodoLists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := addTestTodoItem(t, "Write report", lists[0].ID)
+	// The change is committed in the background; close waits for it.
+	err = h.close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	log, err := h.git("log", "--format=%s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log != "Update tasks\nRecord tasks" {
+		t.Errorf("laptop history: got %q", log)
+	}
+	sync, err := h.sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sync.Pushed {
+		t.Error("the first sync did not push to the empty remote")
+	}
+	err = h.close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h = useTestMachine(t, desktop, filepath.Join(tmp, "desktop"), remote)
+	sync, err = h.sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sync.Pulled != 1 || !sync.Pushed {
+		t.Errorf("desktop sync: got %+v, want 1 task pulled and the merge pushed", sync)
+	}
+	items, err := findTodoItems(report.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatal("the laptop's task did not reach the desktop")
+	}
+	// Both machines change the task, in different fields.
+	items[0].Notes = "Desktop notes"
+	err = updateTodoItem(items[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = h.sync()
+	if err == nil {
+		err = h.close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h = useTestMachine(t, laptop, filepath.Join(tmp, "laptop"), remote)
+	report.Title = "Write the report"
+	err = updateTodoItem(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sync, err = h.sync()
+	if err == nil {
+		err = h.close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sync.Conflicts != 0 {
+		t.Errorf("laptop sync: got %d conflicts, want none", sync.Conflicts)
+	}
+	items, err = findTodoItems(report.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items[0].Title != "Write the report" || items[0].Notes != "Desktop notes" {
+		t.Errorf("merged task: got title %q and notes %q", items[0].Title, items[0].Notes)
+	}
+	status, err := h.git("status", "--porcelain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(status) != "" {
+		t.Errorf("the laptop's working tree is not clean after the sync: %s", status)
+	}
+}
+
+func TestGitHistoryWaitsForIndexLock(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	previous := todosChanged
+	t.Cleanup(func() { todosChanged = previous })
+	openTestDB(t)
+	h, err := openGitHistory(gitConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another process, such as "godo sync", holds the index for a while.
+	lock := filepath.Join(h.dir, ".git", "index.lock")
+	err = os.WriteFile(lock, nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		_ = os.Remove(lock)
+	}()
+	lists, err := getTodoLists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addTestTodoItem(t, "Write report", lists[0].ID)
+	err = h.close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := h.git("status", "--porcelain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != "" {
+		t.Errorf("the change was not committed: %s", status)
+	}
+}
+
+func TestGitHistoryLeavesOutTimers(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	previous := todosChanged
+	t.Cleanup(func() { todosChanged = previous })
+	openTestDB(t)
+	h, err := openGitHistory(gitConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lists, err := getTodoLists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := addTestTodoItem(t, "Write report", lists[0].ID)
+	item.RemainingTime = 10 * time.Minute
+	err = updateRemainingTime(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = h.close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := os.ReadFile(filepath.Join(h.dir, gitTasksFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(tasks), "remaining_time") || strings.Contains(string(tasks), "running") {
+		t.Errorf("the history holds timer state: %s", tasks)
+	}
+
+	// A change from elsewhere leaves the timer here as it is.
+	changedTasks := strings.Replace(string(tasks), "Write report", "Write the report", 1)
+	_, err = h.applyFiles(string(tasks), changedTasks, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := findTodoItems(item.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items[0].Title != "Write the report" || items[0].RemainingTime != 10*time.Minute {
+		t.Errorf("applied task: got %q with %s left, want the new title and 10m left", items[0].Title, items[0].RemainingTime)
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tasks are exchanged with calendar apps as RFC 5545 VTODO components. GoDo's
+// own fields without an iCalendar property travel as X-GODO- properties.
+
+const (
+	icalDateLayout     = "20060102"
+	icalDateTimeLayout = "20060102T150405"
+	icalLineLength     = 75
+)
+
+// icalPriorities maps GoDo priorities onto the iCalendar scale, where 1 is
+// the highest, 5 medium, 9 the lowest and 0 undefined.
+var icalPriorities = map[int]int{1: 1, 2: 3, 3: 5, 4: 9}
+
+// writeICalendar writes items as a VCALENDAR of VTODOs.
+func writeICalendar(out io.Writer, items []*TodoItem) error {
+	w := bufio.NewWriter(out)
+	writeICalLine(w, "BEGIN", "VCALENDAR")
+	writeICalLine(w, "VERSION", "2.0")
+	writeICalLine(w, "PRODID", "-//GoDo//GoDo//EN")
+	writeICalLine(w, "X-WR-CALNAME", "GoDo")
+	stamp := time.Now().UTC().Format(icalDateTimeLayout) + "Z"
+	for _, item := range items {
+		writeICalLine(w, "BEGIN", "VTODO")
+		writeICalLine(w, "UID", item.ID.String())
+		writeICalLine(w, "DTSTAMP", stamp)
+		if !item.CreatedAt.IsZero() {
+			writeICalLine(w, "CREATED", item.CreatedAt.UTC().Format(icalDateTimeLayout)+"Z")
+		}
+		writeVTodoFields(w, item)
+		writeICalLine(w, "END", "VTODO")
+	}
+	writeICalLine(w, "END", "VCALENDAR")
+	return w.Flush()
+}
+
+// writeVTodoFields writes the properties of a VTODO that hold the fields of
+// item, which are those patchICalendar replaces.
+func writeVTodoFields(w *bufio.Writer, item *TodoItem) {
+	writeICalLine(w, "SUMMARY", escapeICalText(item.Title))
+	if item.Notes != "" {
+		writeICalLine(w, "DESCRIPTION", escapeICalText(item.Notes))
+	}
+	if !item.DueDate.IsZero() {
+		writeICalLine(w, "DUE;VALUE=DATE", item.DueDate.Format(icalDateLayout))
+	}
+	status := "NEEDS-ACTION"
+	if item.Completed {
+		status = "COMPLETED"
+	} else if item.Running {
+		status = "IN-PROCESS"
+	}
+	writeICalLine(w, "STATUS", status)
+	writeICalLine(w, "PRIORITY", strconv.Itoa(icalPriorities[item.Priority]))
+	if len(item.Tags) > 0 {
+		categories := make([]string, len(item.Tags))
+		for i, tag := range item.Tags {
+			categories[i] = escapeICalText(tag)
+		}
+		writeICalLine(w, "CATEGORIES", strings.Join(categories, ","))
+	}
+	if item.Recurrence != "" {
+		writeICalLine(w, "RRULE", item.Recurrence)
+	}
+	writeICalLine(w, "X-GODO-DURATION", item.Duration)
+}
+
+// patchedICalProperties are the VTODO properties patchICalendar replaces.
+var patchedICalProperties = map[string]bool{
+	"DTSTAMP": true, "LAST-MODIFIED": true, "SUMMARY": true, "DESCRIPTION": true, "DUE": true, "STATUS": true,
+	"PRIORITY": true, "CATEGORIES": true, "RRULE": true, "X-GODO-DURATION": true,
+}
+
+// patchICalendar writes the calendar in data with the properties of its VTODO
+// that GoDo maps replaced by the fields of item. Everything else is kept as
+// stored, such as alarms, start dates and the properties of other apps.
+func patchICalendar(out io.Writer, data string, item *TodoItem) error {
+	lines, err := unfoldICalLines(strings.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(out)
+	stamp := time.Now().UTC().Format(icalDateTimeLayout) + "Z"
+	inTodo, patched := false, false
+	// nested counts the open components within the VTODO.
+	nested := 0
+	for _, line := range lines {
+		head, value, _ := strings.Cut(line, ":")
+		name, _, _ := strings.Cut(strings.ToUpper(head), ";")
+		switch {
+		case !inTodo && !patched && name == "BEGIN" && strings.EqualFold(value, "VTODO"):
+			inTodo = true
+		case inTodo && name == "BEGIN":
+			nested++
+		case inTodo && nested > 0 && name == "END":
+			nested--
+		case inTodo && nested == 0 && name == "END":
+			writeICalLine(w, "DTSTAMP", stamp)
+			writeICalLine(w, "LAST-MODIFIED", stamp)
+			writeVTodoFields(w, item)
+			inTodo, patched = false, true
+		case inTodo && nested == 0 && patchedICalProperties[name]:
+			continue
+		}
+		writeICalLine(w, head, value)
+	}
+	if !patched {
+		return errors.New("no VTODO to update")
+	}
+	return w.Flush()
+}
+
+// writeICalLine writes a content line, folded after 75 octets without
+// splitting UTF-8 sequences.
+func writeICalLine(w *bufio.Writer, name, value string) {
+	line := name + ":" + value
+	limit := icalLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// Continuation lines start with the folding space.
+		limit = icalLineLength - 1
+	}
+	w.WriteString(line + "\r\n")
+}
+
+var icalTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+var icalTextUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func escapeICalText(s string) string {
+	return icalTextEscaper.Replace(s)
+}
+
+// icalProperty is an unfolded content line.
+type icalProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// parseICalendar reads the VTODOs of an iCalendar stream. Tasks keep their UID
+// as ID when it is a UUID; other UIDs are hashed into one, so importing the
+// same calendar twice yields the same IDs.
+func parseICalendar(in io.Reader) ([]*TodoItem, error) {
+	properties, err := readICalProperties(in)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*TodoItem
+	var item *TodoItem
+	// nested counts the open components within the VTODO, such as VALARMs,
+	// whose properties are not the task's.
+	nested := 0
+	for _, property := range properties {
+		switch {
+		case item != nil && property.name == "BEGIN":
+			nested++
+		case item != nil && nested > 0 && property.name == "END":
+			nested--
+		case nested > 0:
+		case property.name == "BEGIN" && strings.EqualFold(property.value, "VTODO"):
+			item = importedTodoItem("")
+		case property.name == "END" && strings.EqualFold(property.value, "VTODO"):
+			if item != nil && item.Title != "" {
+				items = append(items, item)
+			}
+			item = nil
+		case item != nil:
+			err = applyICalProperty(item, property)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return items, nil
+}
+
+func applyICalProperty(item *TodoItem, property icalProperty) error {
+	switch property.name {
+	case "UID":
+		id, err := uuid.Parse(property.value)
+		if err != nil {
+			id = uuid.NewSHA1(uuid.NameSpaceURL, []byte(property.value))
+		}
+		item.ID = id
+	case "SUMMARY":
+		item.Title = icalTextUnescaper.Replace(property.value)
+	case "DESCRIPTION":
+		item.Notes = icalTextUnescaper.Replace(property.value)
+	case "CREATED":
+		created, err := parseICalTime(property)
+		if err == nil {
+			item.CreatedAt = created
+		}
+	case "DUE":
+		due, err := parseICalTime(property)
+		if err != nil {
+			return fmt.Errorf("invalid DUE %q: %w", property.value, err)
+		}
+		item.DueDate = time.Date(due.Year(), due.Month(), due.Day(), 0, 0, 0, 0, time.Local)
+	case "STATUS":
+		item.Completed = strings.EqualFold(property.value, "COMPLETED")
+	case "PRIORITY":
+		priority, err := strconv.Atoi(property.value)
+		if err != nil {
+			return fmt.Errorf("invalid PRIORITY %q", property.value)
+		}
+		item.Priority = godoPriority(priority)
+	case "CATEGORIES":
+		for _, category := range splitICalList(property.value) {
+			item.Tags = append(item.Tags, strings.ReplaceAll(category, " ", "-"))
+		}
+	case "RRULE":
+		item.Recurrence = property.value
+	case "X-GODO-DURATION":
+		remainingTime, err := time.ParseDuration(property.value)
+		if err != nil {
+			return fmt.Errorf("invalid X-GODO-DURATION %q: %w", property.value, err)
+		}
+		item.Duration = property.value
+		item.RemainingTime = remainingTime
+	}
+	return nil
+}
+
+// godoPriority maps an iCalendar priority onto P1 to P4.
+func godoPriority(priority int) int {
+	switch {
+	case priority == 0:
+		return defaultPriority
+	case priority <= 2:
+		return 1
+	case priority <= 4:
+		return 2
+	case priority <= 6:
+		return 3
+	}
+	return 4
+}
+
+func parseICalTime(property icalProperty) (time.Time, error) {
+	location := time.Local
+	if tzid, ok := property.params["TZID"]; ok {
+		if loaded, err := time.LoadLocation(tzid); err == nil {
+			location = loaded
+		}
+	}
+	value := property.value
+	switch {
+	case len(value) == len(icalDateLayout):
+		return time.ParseInLocation(icalDateLayout, value, time.Local)
+	case strings.HasSuffix(value, "Z"):
+		t, err := time.Parse(icalDateTimeLayout+"Z", value)
+		return t.Local(), err
+	}
+	t, err := time.ParseInLocation(icalDateTimeLayout, value, location)
+	return t.Local(), err
+}
+
+// splitICalList splits a comma separated value, honouring escaped commas.
+func splitICalList(value string) []string {
+	var parts []string
+	var part strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && i+1 < len(value):
+			part.WriteByte(value[i])
+			part.WriteByte(value[i+1])
+			i++
+		case value[i] == ',':
+			parts = append(parts, icalTextUnescaper.Replace(part.String()))
+			part.Reset()
+		default:
+			part.WriteByte(value[i])
+		}
+	}
+	if part.Len() > 0 {
+		parts = append(parts, icalTextUnescaper.Replace(part.String()))
+	}
+	return parts
+}
+
+// unfoldICalLines returns the content lines of in, unfolded.
+func unfoldICalLines(in io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// readICalProperties unfolds and splits the content lines of in.
+func readICalProperties(in io.Reader) ([]icalProperty, error) {
+	lines, err := unfoldICalLines(in)
+	if err != nil {
+		return nil, err
+	}
+
+	properties := make([]icalProperty, 0, len(lines))
+	for _, line := range lines {
+		// The value starts at the first colon outside a quoted parameter.
+		quoted := false
+		colon := -1
+		for i, r := range line {
+			if r == '"' {
+				quoted = !quoted
+			} else if r == ':' && !quoted {
+				colon = i
+				break
+			}
+		}
+		if colon < 0 {
+			return nil, fmt.Errorf("invalid iCalendar line %q", line)
+		}
+
+		head := strings.Split(line[:colon], ";")
+		property := icalProperty{
+			name:   strings.ToUpper(head[0]),
+			params: map[string]string{},
+			value:  line[colon+1:],
+		}
+		for _, param := range head[1:] {
+			key, value, _ := strings.Cut(param, "=")
+			property.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+		}
+		properties = append(properties, property)
+	}
+	return properties, nil
+}
+
+// calendarFeed serves the tasks of all lists, or of ?list=<id>, as a live
+// iCalendar feed that calendar apps can subscribe to.
+func (s *restServer) calendarFeed(w http.ResponseWriter, r *http.Request) {
+	items, err := s.daemon.list(rpcListParams{ListID: r.URL.Query().Get("list"), All: r.URL.Query().Get("list") == ""})
+	if err != nil {
+		writeRESTError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="godo.ics"`)
+	_ = writeICalendar(w, items)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseICalendarSkipsNestedComponents(t *testing.T) {
+	tests := []struct {
+		name      string
+		component string
+	}{
+		{"alarm", "BEGIN:VALARM\r\nACTION:DISPLAY\r\nSUMMARY:Alarm\r\nDESCRIPTION:Reminder\r\nTRIGGER:-PT15M\r\nEND:VALARM\r\n"},
+		{"unknown", "BEGIN:X-VENDOR-THING\r\nUID:other\r\nSUMMARY:Vendor\r\nBEGIN:X-INNER\r\nPRIORITY:9\r\nEND:X-INNER\r\nEND:X-VENDOR-THING\r\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calendar := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VTODO\r\n" +
+				"UID:9b2c8a4e-5f0d-4c3e-8a7b-1d2e3f405162\r\nSUMMARY:Pay rent\r\n" +
+				"DESCRIPTION:Bank transfer\r\nPRIORITY:1\r\n" +
+				test.component + "END:VTODO\r\nEND:VCALENDAR\r\n"
+			items, err := parseICalendar(strings.NewReader(calendar))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != 1 {
+				t.Fatalf("got %d tasks, want 1", len(items))
+			}
+			item := items[0]
+			if item.Title != "Pay rent" || item.Notes != "Bank transfer" || item.Priority != 1 ||
+				item.ID.String() != "9b2c8a4e-5f0d-4c3e-8a7b-1d2e3f405162" {
+				t.Errorf("got %q, %q, P%d, %s", item.Title, item.Notes, item.Priority, item.ID)
+			}
+		})
+	}
+}
+
+func TestPatchICalendarKeepsUnmappedProperties(t *testing.T) {
+	stored := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//Other//EN\r\nBEGIN:VTODO\r\n" +
+		"UID:9b2c8a4e-5f0d-4c3e-8a7b-1d2e3f405162\r\nDTSTAMP:20240101T000000Z\r\n" +
+		"SUMMARY:Pay rent\r\nDESCRIPTION:Bank transfer\r\nDTSTART;VALUE=DATE:20240101\r\n" +
+		"PERCENT-COMPLETE:50\r\nX-OTHER-FLAG:yes\r\n" +
+		"BEGIN:VALARM\r\nACTION:DISPLAY\r\nDESCRIPTION:Reminder\r\nTRIGGER:-PT15M\r\nEND:VALARM\r\n" +
+		"END:VTODO\r\nEND:VCALENDAR\r\n"
+	items, err := parseICalendar(strings.NewReader(stored))
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := items[0]
+	item.Title = "Pay the rent"
+	item.Notes = ""
+
+	var out strings.Builder
+	err = patchICalendar(&out, stored, item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patched := out.String()
+	for _, want := range []string{"PRODID:-//Other//EN", "DTSTART;VALUE=DATE:20240101", "PERCENT-COMPLETE:50",
+		"X-OTHER-FLAG:yes", "DESCRIPTION:Reminder", "SUMMARY:Pay the rent"} {
+		if !strings.Contains(patched, want+"\r\n") {
+			t.Errorf("patched calendar lacks %q:\n%s", want, patched)
+		}
+	}
+	for _, unwanted := range []string{"SUMMARY:Pay rent", "DESCRIPTION:Bank transfer", "DTSTAMP:20240101T000000Z"} {
+		if strings.Contains(patched, unwanted) {
+			t.Errorf("patched calendar still has %q:\n%s", unwanted, patched)
+		}
+	}
+
+	items, err = parseICalendar(strings.NewReader(patched))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Title != "Pay the rent" || items[0].Notes != "" {
+		t.Errorf("patched calendar reads back as %+v", items)
+	}
+}
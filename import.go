@@ -0,0 +1,532 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/google/uuid"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type importFormat string
+
+const (
+	importGoDo        importFormat = "godo"
+	importTodoTxt     importFormat = "todotxt"
+	importTaskwarrior importFormat = "taskwarrior"
+	importMarkdown    importFormat = "markdown"
+	importICal        importFormat = "ical"
+)
+
+var importFormats = []importFormat{importGoDo, importTodoTxt, importTaskwarrior, importMarkdown, importICal}
+
+func parseImportFormat(s string) (importFormat, error) {
+	for _, format := range importFormats {
+		if string(format) == strings.ToLower(s) {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("unknown import format %q, want godo, todotxt, taskwarrior, markdown or ical", s)
+}
+
+// detectImportFormat guesses the format of data from the file name, falling
+// back to its content.
+func detectImportFormat(name string, data []byte) importFormat {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		if isGoDoExport(data) {
+			return importGoDo
+		}
+		return importTaskwarrior
+	case ".md", ".markdown":
+		return importMarkdown
+	case ".txt":
+		return importTodoTxt
+	case ".ics":
+		return importICal
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("BEGIN:VCALENDAR")) {
+		return importICal
+	}
+	if isGoDoExport(trimmed) {
+		return importGoDo
+	}
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		return importTaskwarrior
+	}
+	if markdownTaskLine.Match(data) {
+		return importMarkdown
+	}
+	return importTodoTxt
+}
+
+// isGoDoExport tells GoDo's JSON export apart from Taskwarrior's, which is an
+// array or one object per task.
+func isGoDoExport(data []byte) bool {
+	var document struct {
+		Version int             `json:"version"`
+		Tasks   json.RawMessage `json:"tasks"`
+	}
+	err := json.Unmarshal(data, &document)
+	return err == nil && document.Version > 0 && document.Tasks != nil
+}
+
+// parseImport reads tasks in format from in and returns them as new tasks of
+// the list with listID. They are not saved. Only GoDo's own JSON export
+// returns lists, those its tasks belong to, which keep them. A file without
+// any task is an error, as it most likely is in another format.
+func parseImport(in io.Reader, format importFormat, listID string) ([]*TodoList, []*TodoItem, error) {
+	var lists []*TodoList
+	var items []*TodoItem
+	var err error
+	switch format {
+	case importGoDo:
+		lists, items, err = parseGoDoJSON(in, listID)
+	case importTodoTxt:
+		items, err = parseTodoTxt(in)
+	case importTaskwarrior:
+		items, err = parseTaskwarrior(in)
+	case importMarkdown:
+		items, err = parseMarkdownChecklist(in)
+	case importICal:
+		items, err = parseICalendar(in)
+	default:
+		return nil, nil, fmt.Errorf("unknown import format %q", format)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(items) == 0 {
+		return nil, nil, fmt.Errorf("no tasks found reading the file as %s", format)
+	}
+	if lists == nil {
+		for _, item := range items {
+			item.ListID = listID
+		}
+	}
+	return lists, items, nil
+}
+
+// parseGoDoJSON reads the JSON export, which keeps every field of the tasks
+// and the lists they belong to. Tasks of lists missing from the export go to
+// the list with listID.
+func parseGoDoJSON(in io.Reader, listID string) ([]*TodoList, []*TodoItem, error) {
+	var document exportDocument
+	err := json.NewDecoder(in).Decode(&document)
+	if err != nil {
+		return nil, nil, err
+	}
+	if document.Version > exportVersion {
+		return nil, nil, fmt.Errorf("export version %d is newer than this version of GoDo reads", document.Version)
+	}
+
+	lists := map[string]bool{}
+	for _, list := range document.Lists {
+		lists[list.ID] = true
+	}
+	for _, item := range document.Tasks {
+		if !lists[item.ListID] {
+			item.ListID = listID
+		}
+		// A timer running during the export is paused in the import.
+		item.Running = false
+	}
+	if document.Lists == nil {
+		document.Lists = []*TodoList{}
+	}
+	return document.Lists, document.Tasks, nil
+}
+
+// importedTodoItem returns a task with the defaults used for fields the
+// imported formats have no equivalent for.
+func importedTodoItem(title string) *TodoItem {
+	item, _ := newTodoItem(title, newTaskDuration(), "")
+	return item
+}
+
+// todo.txt priorities are letters; A to C map onto P1 to P3 and everything
+// else onto the default priority.
+var todoTxtPriority = regexp.MustCompile(`^\(([A-Z])\) `)
+
+var todoTxtDate = regexp.MustCompile(`^\d{4}-\d{2}-\d{2} `)
+
+func parseTodoTxt(in io.Reader) ([]*TodoItem, error) {
+	var items []*TodoItem
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		item := importedTodoItem("")
+
+		if strings.HasPrefix(line, "x ") {
+			item.Completed = true
+			line = strings.TrimPrefix(line, "x ")
+			// A completed task has its completion date first.
+			if todoTxtDate.MatchString(line) {
+				line = line[len(dueDateLayout)+1:]
+			}
+		}
+		if match := todoTxtPriority.FindStringSubmatch(line); match != nil {
+			item.Priority = todoTxtPriorityLevel(match[1])
+			line = line[len(match[0]):]
+		}
+		if todoTxtDate.MatchString(line) {
+			created, err := time.ParseInLocation(dueDateLayout, line[:len(dueDateLayout)], time.Local)
+			if err == nil {
+				item.CreatedAt = created
+			}
+			line = line[len(dueDateLayout)+1:]
+		}
+
+		var words []string
+		for _, word := range strings.Fields(line) {
+			switch {
+			case len(word) > 1 && (word[0] == '+' || word[0] == '@'):
+				item.Tags = append(item.Tags, word[1:])
+			case strings.HasPrefix(word, "due:"):
+				due, err := time.ParseInLocation(dueDateLayout, strings.TrimPrefix(word, "due:"), time.Local)
+				if err != nil {
+					words = append(words, word)
+					continue
+				}
+				item.DueDate = due
+			case strings.HasPrefix(word, "pri:") && len(word) == 5:
+				// Some clients keep the priority of completed tasks here.
+				item.Priority = todoTxtPriorityLevel(word[4:])
+			default:
+				words = append(words, word)
+			}
+		}
+		item.Title = strings.Join(words, " ")
+		if item.Title != "" {
+			items = append(items, item)
+		}
+	}
+	return items, scanner.Err()
+}
+
+func todoTxtPriorityLevel(letter string) int {
+	level := int(strings.ToUpper(letter)[0]-'A') + 1
+	if level < 1 || level > len(priorityLabels) {
+		return defaultPriority
+	}
+	return level
+}
+
+// taskwarriorTask holds the fields of a "task export" task that GoDo can use.
+type taskwarriorTask struct {
+	UUID        string   `json:"uuid"`
+	Description string   `json:"description"`
+	Status      string   `json:"status"`
+	Entry       string   `json:"entry"`
+	Due         string   `json:"due"`
+	Priority    string   `json:"priority"`
+	Project     string   `json:"project"`
+	Tags        []string `json:"tags"`
+	Annotations []struct {
+		Description string `json:"description"`
+	} `json:"annotations"`
+}
+
+const taskwarriorTimeLayout = "20060102T150405Z"
+
+var taskwarriorPriorities = map[string]int{"H": 1, "M": 2, "L": 3}
+
+// parseTaskwarrior reads the output of "task export", which is a JSON array
+// in Taskwarrior 2.5 and later and one JSON object per line before that.
+func parseTaskwarrior(in io.Reader) ([]*TodoItem, error) {
+	var tasks []taskwarriorTask
+	decoder := json.NewDecoder(in)
+	for {
+		var raw json.RawMessage
+		err := decoder.Decode(&raw)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+			var batch []taskwarriorTask
+			err = json.Unmarshal(raw, &batch)
+			tasks = append(tasks, batch...)
+		} else {
+			var task taskwarriorTask
+			err = json.Unmarshal(raw, &task)
+			tasks = append(tasks, task)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var items []*TodoItem
+	for _, task := range tasks {
+		if task.Status == "deleted" || task.Description == "" {
+			continue
+		}
+		item := importedTodoItem(task.Description)
+		if id, err := uuid.Parse(task.UUID); err == nil {
+			item.ID = id
+		}
+		item.Completed = task.Status == "completed"
+		if priority, ok := taskwarriorPriorities[task.Priority]; ok {
+			item.Priority = priority
+		}
+		if entry, err := time.Parse(taskwarriorTimeLayout, task.Entry); err == nil {
+			item.CreatedAt = entry.Local()
+		}
+		if due, err := time.Parse(taskwarriorTimeLayout, task.Due); err == nil {
+			due = due.Local()
+			item.DueDate = time.Date(due.Year(), due.Month(), due.Day(), 0, 0, 0, 0, time.Local)
+		}
+		if task.Project != "" {
+			item.Tags = append(item.Tags, task.Project)
+		}
+		item.Tags = append(item.Tags, task.Tags...)
+		var notes []string
+		for _, annotation := range task.Annotations {
+			notes = append(notes, "- "+annotation.Description)
+		}
+		item.Notes = strings.Join(notes, "\n")
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// markdownTaskLine matches "- [ ] Task (25m) #tag" checklist lines as written
+// by the Markdown export, with "*" or "+" bullets and any indentation.
+var markdownTaskLine = regexp.MustCompile(`(?m)^\s*[-*+] \[([ xX])\] (.+)$`)
+
+var markdownDuration = regexp.MustCompile(`\s*\(([0-9hms.]+)\)$`)
+
+func parseMarkdownChecklist(in io.Reader) ([]*TodoItem, error) {
+	var items []*TodoItem
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		match := markdownTaskLine.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+
+		var words, tags []string
+		for _, word := range strings.Fields(match[2]) {
+			if len(word) > 1 && word[0] == '#' {
+				tags = append(tags, word[1:])
+			} else {
+				words = append(words, word)
+			}
+		}
+		title := strings.Join(words, " ")
+		duration := newTaskDuration()
+		if durationMatch := markdownDuration.FindStringSubmatch(title); durationMatch != nil {
+			if _, err := time.ParseDuration(durationMatch[1]); err == nil {
+				duration = durationMatch[1]
+				title = strings.TrimSuffix(title, durationMatch[0])
+			}
+		}
+		if title == "" {
+			continue
+		}
+
+		item, err := newTodoItem(title, duration, "")
+		if err != nil {
+			return nil, err
+		}
+		item.Completed = match[1] != " "
+		item.Tags = tags
+		items = append(items, item)
+	}
+	return items, scanner.Err()
+}
+
+// importDuplicates reports which items already exist, either with the same ID
+// or with the same title in the same list, archived tasks included. Repeated
+// items within the import itself count as duplicates too, so importing a file
+// twice adds nothing the second time.
+func importDuplicates(items []*TodoItem) ([]bool, error) {
+	existing, err := getAllTodoItems()
+	if err != nil {
+		return nil, err
+	}
+	archived, err := getArchivedTodoItems()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	key := func(item *TodoItem) string {
+		return item.ListID + "\x00" + strings.ToLower(strings.TrimSpace(item.Title))
+	}
+	for _, item := range append(existing, archived...) {
+		seen[item.ID.String()] = true
+		seen[key(item)] = true
+	}
+
+	duplicates := make([]bool, len(items))
+	for i, item := range items {
+		// A backup may hold an archived task next to a live one with the
+		// same title, so archived tasks only match by ID.
+		if !item.ArchivedAt.IsZero() {
+			duplicates[i] = seen[item.ID.String()]
+			seen[item.ID.String()] = true
+			continue
+		}
+		duplicates[i] = seen[item.ID.String()] || seen[key(item)]
+		seen[item.ID.String()] = true
+		seen[key(item)] = true
+	}
+	return duplicates, nil
+}
+
+// saveImportedTodoItems saves the items that are not duplicates and returns
+// the saved ones. With the lists of a GoDo JSON export it creates the lists
+// that do not exist yet and stores every field of the items as exported;
+// without them the items are added at the end of their list.
+func saveImportedTodoItems(lists []*TodoList, items []*TodoItem, duplicates []bool) ([]*TodoItem, error) {
+	if lists != nil {
+		err := saveMissingTodoLists(lists)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var saved []*TodoItem
+	for i, item := range items {
+		if duplicates[i] {
+			continue
+		}
+		var err error
+		if lists != nil {
+			err = replaceTodoItem(item)
+		} else {
+			err = saveTodoItem(item)
+		}
+		if err != nil {
+			return saved, err
+		}
+		saved = append(saved, item)
+	}
+	return saved, nil
+}
+
+func showImportDialog(a fyne.App, w fyne.Window) {
+	openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			showError(w, "choose the file", err)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			showError(w, "read the file", err)
+			return
+		}
+		format := detectImportFormat(reader.URI().Name(), data)
+		lists, items, err := parseImport(bytes.NewReader(data), format, currentList.ID)
+		if err != nil {
+			showError(w, "import the file", err)
+			return
+		}
+		showImportPreview(a, w, lists, items)
+	}, w)
+	openDialog.SetFilter(storage.NewExtensionFileFilter([]string{".txt", ".json", ".md", ".markdown", ".ics"}))
+	openDialog.Show()
+}
+
+// showImportPreview lists the parsed tasks, marking duplicates that will be
+// skipped, and saves the rest when confirmed.
+func showImportPreview(a fyne.App, w fyne.Window, lists []*TodoList, items []*TodoItem) {
+	duplicates, err := importDuplicates(items)
+	if err != nil {
+		showError(w, "look for duplicate tasks", err)
+		return
+	}
+
+	newCount := 0
+	rows := make([]fyne.CanvasObject, len(items))
+	for i, item := range items {
+		status := widget.NewIcon(theme.ContentAddIcon())
+		if duplicates[i] {
+			status = widget.NewIcon(theme.ContentCopyIcon())
+		} else {
+			newCount++
+		}
+		done := ""
+		if item.Completed {
+			done = "✓"
+		}
+		due := ""
+		if !item.DueDate.IsZero() {
+			due = item.DueDate.Format(dueDateLayout)
+		}
+		rows[i] = container.NewHBox(
+			status,
+			widget.NewLabel(done),
+			widget.NewLabel(priorityLabel(item.Priority)),
+			widget.NewLabel(item.Title),
+			widget.NewLabel(item.Duration),
+			widget.NewLabel(due),
+			widget.NewLabel(tagsLabel(item.Tags)),
+		)
+	}
+
+	target := currentList.Name
+	if lists != nil {
+		target = "the lists they were exported from"
+	}
+	summary := widget.NewLabel(fmt.Sprintf("%d new tasks for %s, %d duplicates will be skipped.",
+		newCount, target, len(items)-newCount))
+	content := container.NewBorder(summary, nil, nil, nil, container.NewVScroll(container.NewVBox(rows...)))
+
+	preview := dialog.NewCustomConfirm("Import tasks", fmt.Sprintf("Import %d", newCount), "Cancel", content, func(ok bool) {
+		if !ok {
+			return
+		}
+		saved, err := saveImportedTodoItems(lists, items, duplicates)
+		if err != nil {
+			showError(w, "import all tasks", err)
+		}
+		switch {
+		case lists != nil:
+			// The import may have added lists.
+			err = reloadTodoList()
+			if err != nil {
+				showError(w, "reload the tasks", err)
+			}
+			refreshLists(a, w)
+			return
+		case daemon != nil:
+			err = switchList(currentList)
+			if err != nil {
+				showError(w, "reload the tasks", err)
+			}
+		default:
+			changeTodoItems(func(items []*TodoItem) []*TodoItem {
+				return append(items, saved...)
+			})
+		}
+		refreshTodoList(a, w)
+	}, w)
+	preview.Resize(fyne.NewSize(600, 400))
+	preview.Show()
+}
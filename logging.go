@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// GoDo logs JSON lines with log/slog to godo.log in $XDG_STATE_HOME/godo,
+// usually ~/.local/state/godo, and, unless it runs a CLI command whose output
+// they would clutter, to stderr. Fyne's messages, which use the standard log
+// package, go through the same logger. The file is rotated once it grows past
+// maxLogSize, keeping keptLogs older files as godo.log.1, godo.log.2 and so on.
+
+const (
+	logFile    = "godo.log"
+	maxLogSize = 1 << 20
+	keptLogs   = 3
+)
+
+// stateDir returns $XDG_STATE_HOME/godo, ~/.local/state/godo if unset.
+func stateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "godo"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", "godo"), nil
+}
+
+// initLogging makes the log file the default logger's output, next to stderr
+// unless cli is set. Without a log file GoDo logs to stderr only, and CLI
+// commands not at all.
+func initLogging(cli bool) {
+	stderrLogged = !cli
+	var out io.Writer = os.Stderr
+	if cli {
+		out = io.Discard
+	}
+	dir, err := stateDir()
+	if err == nil {
+		var file *rotatingLog
+		file, err = openRotatingLog(filepath.Join(dir, logFile))
+		if err == nil && cli {
+			out = file
+		} else if err == nil {
+			out = logWriters{os.Stderr, file}
+		}
+	}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(out, nil)))
+	if err != nil {
+		slog.Warn("no log file", "error", err)
+	}
+}
+
+// stderrLogged is set unless initLogging left stderr to a CLI command.
+var stderrLogged bool
+
+// fatal logs err, which GoDo cannot go on after, and exits.
+func fatal(err error) {
+	slog.Error("fatal", "error", err)
+	if !stderrLogged {
+		fmt.Fprintln(os.Stderr, "godo:", err)
+	}
+	os.Exit(1)
+}
+
+// logWriters writes to each of its writers even if another fails, so that a
+// closed stderr does not stop the log file and a full disk does not stop
+// stderr.
+type logWriters []io.Writer
+
+func (ws logWriters) Write(p []byte) (int, error) {
+	var errs []error
+	for _, w := range ws {
+		_, err := w.Write(p)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == len(ws) {
+		return 0, errors.Join(errs...)
+	}
+	return len(p), nil
+}
+
+// rotatingLog is a log file that is moved aside once it reaches maxLogSize.
+type rotatingLog struct {
+	lock sync.Mutex
+	path string
+	file *os.File
+	size int64
+}
+
+func openRotatingLog(path string) (*rotatingLog, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, err
+	}
+	l := &rotatingLog{path: path}
+	err = l.open()
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *rotatingLog) open() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	l.file, l.size = file, info.Size()
+	return nil
+}
+
+func (l *rotatingLog) Write(p []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.file != nil && l.size > 0 && l.size+int64(len(p)) > maxLogSize {
+		err := l.rotate()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "rotating the log:", err)
+		}
+	}
+	if l.file == nil {
+		// The file could not be reopened after rotating; stderr still gets
+		// the line.
+		err := l.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := l.file.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// rotate renames godo.log to godo.log.1, godo.log.1 to godo.log.2 and so on,
+// dropping the oldest, and starts a new godo.log. godo.log is moved aside
+// first, so that the older files stay as they are if it cannot be; it is then
+// reopened to go on writing to it, and rotating is tried again after another
+// maxLogSize. l.file is nil if the file could not be reopened.
+func (l *rotatingLog) rotate() error {
+	err := l.file.Close()
+	l.file = nil
+	if err == nil {
+		rotating := l.path + ".rotating"
+		err = os.Rename(l.path, rotating)
+		if err == nil {
+			for i := keptLogs - 1; i > 0; i-- {
+				_ = os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+			}
+			err = os.Rename(rotating, l.path+".1")
+			if err != nil {
+				_ = os.Rename(rotating, l.path)
+			}
+		}
+	}
+	openErr := l.open()
+	if err != nil && openErr == nil {
+		l.size = 0
+	}
+	return errors.Join(err, openErr)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingLogKeepsBackupsWhenRenameFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), logFile)
+	for i, content := range []string{"first\n", "second\n"} {
+		err := os.WriteFile(fmt.Sprintf("%s.%d", path, i+1), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A directory that is not empty cannot be renamed over, so moving
+	// godo.log aside fails.
+	blocker := path + ".rotating"
+	err := os.MkdirAll(filepath.Join(blocker, "in-the-way"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := openRotatingLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.file.Close() })
+
+	line := []byte(strings.Repeat("x", maxLogSize/2) + "\n")
+	for i := 0; i < 3; i++ {
+		_, err = l.Write(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkLogFiles := func(want map[string]string) {
+		t.Helper()
+		for suffix, content := range want {
+			data, err := os.ReadFile(path + suffix)
+			if err != nil {
+				t.Errorf("godo.log%s: %v", suffix, err)
+				continue
+			}
+			if string(data) != content {
+				t.Errorf("godo.log%s has %d bytes, want %d", suffix, len(data), len(content))
+			}
+		}
+	}
+	checkLogFiles(map[string]string{"": strings.Repeat(string(line), 3), ".1": "first\n", ".2": "second\n"})
+	_, err = os.Stat(path + ".3")
+	if err == nil {
+		t.Error("a failed rotation shifted the older logs")
+	}
+
+	err = os.RemoveAll(blocker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = l.Write(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkLogFiles(map[string]string{"": string(line), ".1": strings.Repeat(string(line), 3), ".2": "first\n", ".3": "second\n"})
+}
@@ -3,7 +3,9 @@
 package main
 
 import (
-	"database/sql"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
@@ -15,58 +17,255 @@ import (
 	"github.com/bradhe/stopwatch"
 	"github.com/google/uuid"
 	_ "github.com/mattn/go-sqlite3"
-	"image/color"
-	"log"
+	"log/slog"
+	"os"
 	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
 type TodoItem struct {
 	ID            uuid.UUID
-	Checkbox      *widget.Check
-	Task          *widget.Label
-	Duration      *widget.Label
-	Timer         *widget.Label
+	Title         string
+	Duration      string
+	Completed     bool
 	Running       bool
 	Done          chan bool
 	RemainingTime time.Duration
 	Stopwatch     stopwatch.Watch
+	Priority      int
+	DueDate       time.Time
+	CreatedAt     time.Time
+	ListID        string
+	Position      float64
+	Notes         string
+	Tags          []string
+	ArchivedAt    time.Time
+	// Recurrence is an iCalendar RRULE value such as "FREQ=WEEKLY", kept so
+	// that recurring tasks survive an iCalendar round trip.
+	Recurrence string
+	// StartedAt is when the running timer was started, the start of the
+	// session recorded when it stops.
+	StartedAt time.Time
+}
+
+type TodoList struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	SortMode sortMode `json:"sort_mode"`
+	// Color is the "#rrggbb" color marking the list's tasks, none if empty.
+	Color string `json:"color,omitempty"`
 }
 
 var todoList []*TodoItem
+var todoLists []*TodoList
+var currentList *TodoList
+
+// todoListLock guards todoList. Fyne runs the event handlers of each window
+// on a goroutine of its own, and the tray, the daemon subscription and the
+// vault watcher have theirs. todoList is replaced but never changed in place,
+// so the tasks currentTodoItems returns stay valid without the lock.
+var todoListLock sync.Mutex
+
+// currentTodoItems returns the tasks of the current list.
+func currentTodoItems() []*TodoItem {
+	todoListLock.Lock()
+	defer todoListLock.Unlock()
+	return todoList
+}
+
+// changeTodoItems replaces the tasks of the current list with what change
+// returns for them. change must not modify the slice it is given.
+func changeTodoItems(change func(items []*TodoItem) []*TodoItem) {
+	todoListLock.Lock()
+	defer todoListLock.Unlock()
+	todoList = change(todoList)
+}
+
+// todoItemChanged is called after a timer changes an item, from the timer's
+// goroutine. The GUI uses it to update the row showing the item.
+var todoItemChanged = func(item *TodoItem) {}
+
+// timerFinished is called when the timer of an item runs out, from the
+// timer's goroutine. The GUI uses it to show a notification.
+var timerFinished = func(item *TodoItem) {}
 
 func main() {
-	initDB()
-	defer func(db *sql.DB) {
+	hidden := len(os.Args) == 2 && os.Args[1] == "--hidden"
+	cli := len(os.Args) > 1 && !hidden
+	initLogging(cli)
+	config, err := loadConfig()
+	if err != nil {
+		fatal(err)
+	}
+	currentConfig = config
+
+	// The settings window may switch to another database.
+	initDB(config.Database.Path)
+	defer func() {
+		closeMirrors()
 		err := db.Close()
 		if err != nil {
-			fmt.Println("db-error", err)
-			return
+			slog.Error("closing the database", "error", err)
 		}
-	}(db)
+	}()
+
+	daemon, _ = dialDaemon()
+
+	if cli {
+		code := runCLI(os.Args[1:])
+		closeMirrors()
+		_ = db.Close()
+		os.Exit(code)
+	}
 
 	a := app.NewWithID("GoDo")
+	a.Settings().SetTheme(newGoDoTheme(config.Appearance))
 	w := a.NewWindow("GoDo")
 	w.SetIcon(resourceLogoWindowmanagerWhitePng)
 
-	todoList, _ = getTodoItems()
+	mirrorErr := openMirrors(config)
 
-	if desk, ok := a.(desktop.App); ok {
-		m := fyne.NewMenu("GoDo", fyne.NewMenuItem("show", func() { w.Show() }))
-		desk.SetSystemTrayMenu(m)
-		desk.SetSystemTrayIcon(resourceLogoWindowmanagerWhitePng)
+	todoItemChanged = refreshTimer
+	timerFinished = func(item *TodoItem) {
+		if currentConfig.Timer.Notify {
+			a.SendNotification(fyne.NewNotification("Timer finished", item.Title))
+		}
 	}
+	purgeExpiredArchive()
 
+	// No timer runs yet, so GoDo may still exit here.
+	todoLists, err = getTodoLists()
+	if err != nil {
+		fatal(err)
+	}
+	currentList = todoLists[0]
+	todoList, err = loadTodoItems(currentList.ID)
+	if err != nil {
+		fatal(err)
+	}
 	w.SetContent(makeGUI(a, w))
-	w.Resize(fyne.NewSize(400, 200))
+
+	if daemon != nil {
+		err := daemon.subscribe(func(event daemonEvent) {
+			applyDaemonEvent(a, w, event)
+		}, func() {
+			daemonLost(a, w)
+		})
+		if err != nil {
+			slog.Error("daemon subscribe", "error", err)
+		}
+	}
+	watchVault(a, w)
+
+	desk, hasTray := a.(desktop.App)
+	if hasTray {
+		makeTrayMenu(a, w, desk)
+	}
+
+	w.Resize(windowSize(config.Window))
+	// The logo depends on the theme, which may follow the desktop's.
+	settingsChanged := make(chan fyne.Settings)
+	a.Settings().AddChangeListener(settingsChanged)
+	go func() {
+		for range settingsChanged {
+			guiLogo.Resource = logoResource(a)
+			guiLogo.Refresh()
+			refreshTodoList(a, w)
+		}
+	}()
+	addShortcuts(a, w, config.Shortcuts)
 
 	w.SetCloseIntercept(func() {
-		w.Close()
+		// Without a system tray there would be no way back to a hidden window.
+		if hasTray && currentConfig.Window.Close != closeQuits {
+			w.Hide()
+			return
+		}
+		quitGoDo(a)
 	})
-
+	// Being told to quit stops the timers too, storing their remaining time.
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupted
+		quitGoDo(a)
+	}()
+
+	if mirrorErr != nil {
+		showError(w, "open the vault or git history", mirrorErr)
+	}
+	if hidden && hasTray {
+		a.Run()
+		return
+	}
 	w.ShowAndRun()
 }
 
+// openMirrors opens the Markdown vault and the git history set up in config.
+// One failing to open does not keep the other from opening.
+func openMirrors(config *godoConfig) error {
+	var errs []error
+	if config.Vault.Path != "" {
+		store, err := openVault(config.Vault.Path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("vault %s: %w", config.Vault.Path, err))
+		} else {
+			vaultStore = store
+		}
+	}
+	if config.Git.Path != "" {
+		store, err := openGitHistory(config.Git)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("git history %s: %w", config.Git.Path, err))
+		} else {
+			gitStore = store
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// closeMirrors commits the changes the git history has yet to commit and
+// stops mirroring the database to the vault and git history.
+func closeMirrors() {
+	if vaultStore != nil {
+		err := vaultStore.close()
+		if err != nil {
+			slog.Error("vault", "error", err)
+		}
+		vaultStore = nil
+	}
+	if gitStore != nil {
+		err := gitStore.close()
+		if err != nil {
+			slog.Error("git history", "error", err)
+		}
+		gitStore = nil
+	}
+}
+
+// watchVault shows the changes other programs make to the vault. With a
+// daemon running the daemon watches the vault instead.
+func watchVault(a fyne.App, w fyne.Window) {
+	if daemon != nil || vaultStore == nil {
+		return
+	}
+	err := vaultStore.watch(func(items []*TodoItem) {
+		err := reloadTodoList()
+		if err != nil {
+			showError(w, "reload the tasks", err)
+			return
+		}
+		refreshLists(a, w)
+	})
+	if err != nil {
+		slog.Error("vault watch", "error", err)
+	}
+}
+
 func showNewTodoWindow(a fyne.App, w fyne.Window) {
 	inputWindow := a.NewWindow("New Todo")
 	inputWindow.Resize(fyne.NewSize(300, 200))
@@ -74,211 +273,509 @@ func showNewTodoWindow(a fyne.App, w fyne.Window) {
 	taskEntry := widget.NewEntry()
 	taskEntry.SetPlaceHolder("Enter your task...")
 
-	durationSelect := widget.NewSelect([]string{"10s", "1m", "15m", "30m", "1h", "3h"}, func(selected string) {
-		fmt.Println("Selected duration:", selected)
-	})
+	durationSelect := widget.NewSelect(durationPresets(), nil)
+	durationSelect.SetSelected(newTaskDuration())
+
+	prioritySelect := widget.NewSelect(priorityLabels, nil)
+	prioritySelect.SetSelected(priorityLabel(defaultPriority))
+
+	dueEntry := widget.NewEntry()
+	dueEntry.SetPlaceHolder("Due date (YYYY-MM-DD, optional)")
+
+	tagsEntry := widget.NewEntry()
+	tagsEntry.SetPlaceHolder("Tags (space separated, optional)")
 
 	saveCallback := func() {
 		if taskEntry.Text == "" {
-			fmt.Println("Please enter a task description.")
+			showInvalidInput(inputWindow, "Please enter a task description.")
 			return
 		}
 
-		duration, err := time.ParseDuration(durationSelect.Selected)
+		newItem, err := newTodoItem(taskEntry.Text, durationSelect.Selected, currentList.ID)
 		if err != nil {
-			fmt.Println("Invalid duration:", err)
+			showInvalidInput(inputWindow, "Please choose a duration.")
 			return
 		}
 
-		newItem := &TodoItem{
-			ID: uuid.New(),
-			Checkbox: widget.NewCheck("", func(checked bool) {
-				fmt.Println("Checkbox state changed:", checked)
-			}),
-			Task:          widget.NewLabel(taskEntry.Text),
-			Duration:      widget.NewLabel(durationSelect.Selected),
-			Timer:         widget.NewLabel(formatTime(duration)),
-			RemainingTime: duration,
+		var dueDate time.Time
+		if dueEntry.Text != "" {
+			dueDate, err = time.ParseInLocation(dueDateLayout, dueEntry.Text, time.Local)
+			if err != nil {
+				showInvalidInput(inputWindow, "Please enter the due date as YYYY-MM-DD.")
+				return
+			}
 		}
 
-		todoList = append(todoList, newItem)
-		err = saveTodoItem(newItem)
+		newItem.Priority = parsePriority(prioritySelect.Selected)
+		newItem.DueDate = dueDate
+		newItem.Tags = parseTags(tagsEntry.Text)
+
+		newItem, err = addTodoItem(newItem)
 		if err != nil {
-			{
-				log.Fatal(err)
-			}
+			showError(inputWindow, "save the task", err)
 			return
 		}
-		w.SetContent(makeGUI(a, w))
+		if daemon != nil {
+			// The daemon may already have told us about the new task.
+			err = switchList(currentList)
+			if err != nil {
+				showError(w, "reload the tasks", err)
+			}
+		} else {
+			changeTodoItems(func(items []*TodoItem) []*TodoItem {
+				return append(items, newItem)
+			})
+		}
+		refreshTodoList(a, w)
 		inputWindow.Close()
 	}
 
-	inputContainer := container.NewVBox(taskEntry, durationSelect, widget.NewButton("Save", saveCallback))
+	inputContainer := container.NewVBox(taskEntry, durationSelect, prioritySelect, dueEntry, tagsEntry, widget.NewButton("Save", saveCallback))
 	inputWindow.SetContent(inputContainer)
 	inputWindow.Show()
 }
 
-type GoDoTheme struct {
-	fyne.Theme
+// newTodoItem returns an unsaved task with its timer set to duration.
+func newTodoItem(title, duration, listID string) (*TodoItem, error) {
+	remainingTime, err := time.ParseDuration(duration)
+	if err != nil {
+		return nil, err
+	}
+	return &TodoItem{
+		ID:            uuid.New(),
+		Title:         title,
+		Duration:      duration,
+		RemainingTime: remainingTime,
+		Priority:      defaultPriority,
+		CreatedAt:     time.Now(),
+		ListID:        listID,
+	}, nil
 }
 
-func newGoDoTheme() fyne.Theme {
-	return &GoDoTheme{Theme: theme.DefaultTheme()}
+// todoItemHash identifies the editable content of a task, so that syncing
+// can tell whether a task changed since it was last synced.
+func todoItemHash(item *TodoItem) string {
+	due := ""
+	if !item.DueDate.IsZero() {
+		due = item.DueDate.Format(dueDateLayout)
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		item.Title, item.Notes, due, fmt.Sprint(item.Completed), fmt.Sprint(item.Priority),
+		formatTags(item.Tags), item.Recurrence, item.Duration,
+	}, "\x00")))
+	return hex.EncodeToString(sum[:])
 }
 
-func (t *GoDoTheme) Color(name fyne.ThemeColorName, _ fyne.ThemeVariant) color.Color {
-	return t.Theme.Color(name, theme.VariantDark)
+// copyEditableFields copies the fields hashed by todoItemHash from remote to
+// local, resetting the remaining time if the duration changed.
+func copyEditableFields(local, remote *TodoItem) {
+	if local.Duration != remote.Duration && !local.Running {
+		local.RemainingTime = remote.RemainingTime
+	}
+	local.Title = remote.Title
+	local.Notes = remote.Notes
+	local.DueDate = remote.DueDate
+	local.Completed = remote.Completed
+	local.Priority = remote.Priority
+	local.Tags = remote.Tags
+	local.Recurrence = remote.Recurrence
+	local.Duration = remote.Duration
 }
 
-func (t *GoDoTheme) Size(name fyne.ThemeSizeName) float32 {
-	if name == theme.SizeNameText {
-		return 12
+func showNewListWindow(a fyne.App, w fyne.Window) {
+	inputWindow := a.NewWindow("New List")
+	inputWindow.Resize(fyne.NewSize(300, 100))
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("List name...")
+
+	saveCallback := func() {
+		if nameEntry.Text == "" {
+			showInvalidInput(inputWindow, "Please enter a list name.")
+			return
+		}
+
+		list := &TodoList{ID: uuid.New().String(), Name: nameEntry.Text, SortMode: sortByManual}
+		err := saveTodoList(list)
+		if err != nil {
+			showError(inputWindow, "save the list", err)
+			return
+		}
+		todoLists = append(todoLists, list)
+		err = switchList(list)
+		if err != nil {
+			showError(w, "open the list", err)
+		}
+		refreshLists(a, w)
+		inputWindow.Close()
+	}
+
+	inputWindow.SetContent(container.NewVBox(nameEntry, widget.NewButton("Save", saveCallback)))
+	inputWindow.Show()
+}
+
+// switchList shows the tasks of list. The current list stays if they cannot
+// be loaded.
+func switchList(list *TodoList) error {
+	items, err := loadTodoItems(list.ID)
+	if err != nil {
+		return err
+	}
+	todoListLock.Lock()
+	defer todoListLock.Unlock()
+	// Timers run by a daemon keep running while their list is not shown.
+	if daemon == nil {
+		for _, item := range todoList {
+			logTimerError(item.StopTimer())
+		}
+	}
+	if list != currentList {
+		currentList = list
+	}
+	todoList = items
+	return nil
+}
+
+// reloadTodoList reloads the lists and the tasks of the current list after
+// another program changed them, keeping the items of running timers.
+func reloadTodoList() error {
+	lists, err := getTodoLists()
+	if err != nil {
+		return err
+	}
+	items, err := loadTodoItems(currentList.ID)
+	if err != nil {
+		return err
+	}
+	todoListLock.Lock()
+	defer todoListLock.Unlock()
+	todoLists = lists
+	for _, list := range lists {
+		if list.ID == currentList.ID {
+			currentList = list
+		}
+	}
+
+	running := map[uuid.UUID]*TodoItem{}
+	timerLock.Lock()
+	for _, item := range todoList {
+		if item.Running {
+			running[item.ID] = item
+		}
+	}
+	for i, item := range items {
+		if old, ok := running[item.ID]; ok {
+			copyEditableFields(old, item)
+			old.Position = item.Position
+			items[i] = old
+			delete(running, item.ID)
+		}
 	}
-	return t.Theme.Size(name)
+	timerLock.Unlock()
+	for _, item := range running {
+		logTimerError(item.StopTimer())
+	}
+	todoList = items
+	return nil
 }
 
+// timerLock guards the timer state of every task, Running, RemainingTime,
+// StartedAt and Done, which the timer goroutines change. Other goroutines
+// read a task whose timer may run through snapshot.
+var timerLock sync.Mutex
+
 func (item *TodoItem) StartTimer() {
+	timerLock.Lock()
+	defer timerLock.Unlock()
+	// Starting a running timer does nothing.
 	if item.Running {
-		println("TIMER ALREADY STARTED")
 		return
 	}
 
-	if item.Done == nil {
-		item.Done = make(chan bool)
-	}
-
 	if item.RemainingTime <= 0 {
-		item.RemainingTime, _ = time.ParseDuration(item.Duration.Text)
+		item.RemainingTime, _ = time.ParseDuration(item.Duration)
 	}
 
 	item.Running = true
-	go item.runTimer()
+	item.StartedAt = time.Now()
+	item.Done = make(chan bool)
+	go item.runTimer(item.Done)
 }
 
-func (item *TodoItem) runTimer() {
+func (item *TodoItem) runTimer(done chan bool) {
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
+			timerLock.Lock()
+			if item.Done != done {
+				// The timer was stopped while the tick waited for the lock.
+				timerLock.Unlock()
+				return
+			}
 			item.RemainingTime -= time.Second
-			if item.RemainingTime <= 0 {
-				item.StopTimer()
+			finished := item.RemainingTime <= 0
+			var err error
+			if finished {
+				err = item.stopLocked()
+			}
+			timerLock.Unlock()
+
+			if finished {
+				logTimerError(err)
+				timerFinished(item)
 				playSound()
+				todoItemChanged(item)
 				return
 			}
-			item.Timer.SetText(formatTime(item.RemainingTime))
-		case <-item.Done:
+			todoItemChanged(item)
+		case <-done:
 			return
 		}
 	}
 }
 
-func (item *TodoItem) StopTimer() {
+// StopTimer stops the timer and stores the remaining time and the session.
+// The timer stops even if they cannot be stored. Stopping a timer that is not
+// running only stores the remaining time.
+func (item *TodoItem) StopTimer() error {
+	timerLock.Lock()
+	defer timerLock.Unlock()
+	return item.stopLocked()
+}
+
+// stopLocked is StopTimer for callers holding timerLock.
+func (item *TodoItem) stopLocked() error {
 	err := updateRemainingTime(item)
-	if err != nil {
-		return
-	}
 	if item.Running {
+		err = errors.Join(err, saveTimerSession(item.ID, item.StartedAt, time.Now()))
 		item.Running = false
 		close(item.Done)
 		item.Done = nil
 	}
+	return err
 }
 
-func (item *TodoItem) ResetTimer() {
-	item.StopTimer()
-	item.RemainingTime, _ = time.ParseDuration(item.Duration.Text)
-	item.Timer.SetText(formatTime(item.RemainingTime))
-	err := updateRemainingTime(item)
-	if err != nil {
+func (item *TodoItem) ResetTimer() error {
+	timerLock.Lock()
+	err := item.stopLocked()
+	item.RemainingTime, _ = time.ParseDuration(item.Duration)
+	err = errors.Join(err, updateRemainingTime(item))
+	timerLock.Unlock()
+	todoItemChanged(item)
+	return err
+}
+
+// snapshot returns a copy of item that its timer does not change.
+func (item *TodoItem) snapshot() *TodoItem {
+	timerLock.Lock()
+	defer timerLock.Unlock()
+	copied := *item
+	return &copied
+}
+
+// toggleTimer starts item's timer or pauses it, showing failures on w.
+func toggleTimer(w fyne.Window, item *TodoItem) {
+	if item.snapshot().Running {
+		err := stopTimer(item)
+		if err != nil {
+			showError(w, "pause the timer", err)
+		}
 		return
 	}
+	err := startTimer(item)
+	if err != nil {
+		showError(w, "start the timer", err)
+	}
 }
 
+const dueDateLayout = "2006-01-02"
+
 func formatTime(d time.Duration) string {
 	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
 }
 
 func playSound() {
-	cmd := exec.Command("aplay", "sounds/finished.wav")
-	err := cmd.Start()
+	if currentConfig.Timer.Mute {
+		return
+	}
+	err := playSoundFile(soundPath(currentConfig.Timer))
 	if err != nil {
-		log.Fatal(err)
+		slog.Error("sound", "error", err)
 	}
-	err = cmd.Wait()
+}
+
+func playSoundFile(path string) error {
+	cmd := exec.Command("aplay", path)
+	err := cmd.Start()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	return cmd.Wait()
 }
 
 func clearDoneTasks(a fyne.App, w fyne.Window) {
-	var remainingTasks []*TodoItem
-	for _, item := range todoList {
-		if !item.Checkbox.Checked {
-			remainingTasks = append(remainingTasks, item)
-		} else {
-			err := deleteTodoItem(item)
+	archived := map[*TodoItem]bool{}
+	var failed error
+	for _, item := range currentTodoItems() {
+		if !item.Completed {
+			continue
+		}
+		err := stopTimer(item)
+		if err == nil {
+			err = archiveTodoItem(item)
+		}
+		if err != nil {
+			// The task stays to be archived with the next try.
+			failed = err
+			continue
+		}
+		archived[item] = true
+	}
+	changeTodoItems(func(items []*TodoItem) []*TodoItem {
+		var remaining []*TodoItem
+		for _, item := range items {
+			if !archived[item] {
+				remaining = append(remaining, item)
+			}
+		}
+		return remaining
+	})
+	refreshTodoList(a, w)
+	if failed != nil {
+		showError(w, "archive the done tasks", failed)
+	}
+}
+
+func logTimerError(err error) {
+	if err != nil {
+		slog.Error("timer", "error", err)
+	}
+}
+
+// applyDaemonEvent mirrors a change made by the daemon in the GUI.
+func applyDaemonEvent(a fyne.App, w fyne.Window, event daemonEvent) {
+	switch event.Type {
+	case eventAdded, eventUpdated, eventDone, eventRemoved:
+		if event.Item.ListID == currentList.ID {
+			err := switchList(currentList)
 			if err != nil {
-				{
-					log.Fatal(err)
-				}
+				showError(w, "reload the tasks", err)
 				return
 			}
+			refreshTodoList(a, w)
+		}
+		return
+	}
+
+	for _, item := range currentTodoItems() {
+		if item.ID == event.Item.ID {
+			timerLock.Lock()
+			item.RemainingTime = event.Item.RemainingTime
+			item.Running = event.Item.Running
+			timerLock.Unlock()
+			todoItemChanged(item)
 		}
 	}
-	todoList = remainingTasks
-	w.SetContent(makeGUI(a, w))
 }
 
-func buildTodoList(items []*TodoItem) []fyne.CanvasObject {
-	todos := make([]fyne.CanvasObject, len(items))
-	for i, item := range items {
-		startButton := widget.NewButtonWithIcon("Start", theme.MediaPlayIcon(), func(item *TodoItem) func() {
-			return func() {
-				item.StartTimer()
-			}
-		}(item))
-		stopButton := widget.NewButtonWithIcon("Stop", theme.MediaStopIcon(), func(item *TodoItem) func() {
-			return func() {
-				item.StopTimer()
-			}
-		}(item))
-		resetButton := widget.NewButtonWithIcon("Reset", theme.ViewRefreshIcon(), func(item *TodoItem) func() {
-			return func() {
-				item.ResetTimer()
-			}
-		}(item))
+// daemonLost makes the GUI run the timers itself once the daemon is gone.
+// The daemon saves its timers when it stops, so they are reloaded stopped.
+func daemonLost(a fyne.App, w fyne.Window) {
+	items := currentTodoItems()
+	timerLock.Lock()
+	for _, item := range items {
+		// The daemon ran these timers; there is nothing here to stop.
+		item.Running = false
+	}
+	timerLock.Unlock()
+	daemon.Close()
+	daemon = nil
 
-		todos[i] = container.NewHBox(
-			item.Checkbox,
-			item.Task,
-			item.Duration,
-			item.Timer,
-			startButton,
-			stopButton,
-			resetButton,
-		)
+	err := reloadTodoList()
+	if err != nil {
+		showError(w, "reload the tasks", err)
+		return
 	}
-	return todos
+	refreshLists(a, w)
 }
 
+func moveAndRefresh(a fyne.App, w fyne.Window, item *TodoItem, offset int) {
+	err := moveTodoItem(item, offset)
+	if err != nil {
+		showError(w, "move the task", err)
+		return
+	}
+	refreshTodoList(a, w)
+}
+
+// The parts of the window that change with the current list, its tasks and
+// the theme are kept to update them in place. guiRoot holds guiContent, next
+// to the notes panel while one is open.
+var (
+	guiLogo       *canvas.Image
+	guiListSelect *widget.Select
+	guiSortSelect *widget.Select
+	guiListArea   *fyne.Container
+	guiContent    fyne.CanvasObject
+	guiRoot       *fyne.Container
+)
+
 func makeGUI(a fyne.App, w fyne.Window) fyne.CanvasObject {
-	banner := makeBanner(a, w)
-	logo := makeLogo()
-	todoListContainer := makeTodoListContainer()
-
-	return container.NewVBox(
-		logo,
-		banner,
-		container.NewStack(todoListContainer),
-	)
+	guiLogo = makeLogo(a)
+	guiListArea = container.NewStack(makeTodoListContainer(a, w))
+	banner := makeBanner(a, w, guiListArea)
+
+	guiContent = container.NewBorder(container.NewVBox(guiLogo, banner), nil, nil, nil, guiListArea)
+	guiRoot = container.NewStack()
+	refreshNotesPanel(a, w)
+	return guiRoot
+}
+
+// refreshLists shows the current list after switching lists or changes to
+// todoLists.
+func refreshLists(a fyne.App, w fyne.Window) {
+	names := make([]string, len(todoLists))
+	for i, list := range todoLists {
+		names[i] = list.Name
+	}
+	guiListSelect.Options = names
+	setSelectedQuietly(guiListSelect, currentList.Name)
+	setSelectedQuietly(guiSortSelect, sortModeLabels[currentList.SortMode])
+	refreshTodoList(a, w)
 }
 
-func makeBanner(a fyne.App, w fyne.Window) fyne.CanvasObject {
+// setSelectedQuietly selects value without calling the select's OnChanged.
+func setSelectedQuietly(s *widget.Select, value string) {
+	onChanged := s.OnChanged
+	s.OnChanged = nil
+	s.SetSelected(value)
+	s.OnChanged = onChanged
+	s.Refresh()
+}
+
+// refreshNotesPanel shows the notes of notesItem next to the list, or the
+// list alone if no notes are open or their task is no longer in the list.
+func refreshNotesPanel(a fyne.App, w fyne.Window) {
+	if indexOfTodoItem(currentTodoItems(), notesItem) < 0 {
+		notesItem = nil
+	}
+	if notesItem == nil {
+		guiRoot.Objects = []fyne.CanvasObject{guiContent}
+	} else {
+		split := container.NewHSplit(guiContent, makeNotesPanel(a, w, notesItem))
+		split.SetOffset(0.65)
+		guiRoot.Objects = []fyne.CanvasObject{split}
+	}
+	guiRoot.Refresh()
+}
+
+func makeBanner(a fyne.App, w fyne.Window, listArea *fyne.Container) fyne.CanvasObject {
 	toolbar := makeToolbar(a, w)
-	return container.NewVBox(toolbar)
+	searchBar := makeSearchBar(a, w, listArea)
+	return container.NewVBox(toolbar, searchBar)
 }
 
 func makeToolbar(a fyne.App, w fyne.Window) fyne.CanvasObject {
@@ -288,19 +785,138 @@ func makeToolbar(a fyne.App, w fyne.Window) fyne.CanvasObject {
 	clearDoneButton := widget.NewToolbarAction(theme.ContentRemoveIcon(), func() {
 		clearDoneTasks(a, w)
 	})
-	return widget.NewToolbar(addButton, clearDoneButton)
+	newListButton := widget.NewToolbarAction(theme.FolderNewIcon(), func() {
+		showNewListWindow(a, w)
+	})
+	listColorButton := widget.NewToolbarAction(theme.ColorPaletteIcon(), func() {
+		showListColorDialog(a, w)
+	})
+	archiveButton := widget.NewToolbarAction(theme.HistoryIcon(), func() {
+		showArchiveWindow(a, w)
+	})
+	importButton := widget.NewToolbarAction(theme.UploadIcon(), func() {
+		showImportDialog(a, w)
+	})
+	settingsButton := widget.NewToolbarAction(theme.SettingsIcon(), func() {
+		showSettingsWindow(a, w)
+	})
+	shortcutsButton := widget.NewToolbarAction(theme.HelpIcon(), func() {
+		showShortcutsDialog(a, w)
+	})
+	return widget.NewToolbar(
+		addButton,
+		clearDoneButton,
+		archiveButton,
+		importButton,
+		&toolbarObject{makeExportButton(w)},
+		widget.NewToolbarSeparator(),
+		&toolbarObject{makeListSelect(a, w)},
+		newListButton,
+		listColorButton,
+		widget.NewToolbarSeparator(),
+		&toolbarObject{makeSortSelect(a, w)},
+		widget.NewToolbarSpacer(),
+		settingsButton,
+		shortcutsButton,
+	)
 }
 
-func makeTodoListContainer() fyne.CanvasObject {
-	if len(todoList) > 0 {
-		return container.NewVBox(buildTodoList(todoList)...)
+// toolbarObject lets arbitrary widgets such as selects sit in a widget.Toolbar.
+type toolbarObject struct {
+	object fyne.CanvasObject
+}
+
+func (t *toolbarObject) ToolbarObject() fyne.CanvasObject {
+	return t.object
+}
+
+func makeListSelect(a fyne.App, w fyne.Window) fyne.CanvasObject {
+	names := make([]string, len(todoLists))
+	for i, list := range todoLists {
+		names[i] = list.Name
+	}
+	guiListSelect = widget.NewSelect(names, nil)
+	guiListSelect.SetSelected(currentList.Name)
+	guiListSelect.OnChanged = func(selected string) {
+		for _, list := range todoLists {
+			if list.Name == selected && list != currentList {
+				err := switchList(list)
+				if err != nil {
+					showError(w, "open the list", err)
+				}
+				refreshLists(a, w)
+				return
+			}
+		}
+	}
+	return guiListSelect
+}
+
+// showListColorDialog lets the user pick the color marking the current
+// list's tasks.
+func showListColorDialog(a fyne.App, w fyne.Window) {
+	list := currentList
+	showColorDialog("Color of "+list.Name, "No color", func(hex string) {
+		previous := list.Color
+		list.Color = hex
+		err := updateListColor(list)
+		if err != nil {
+			list.Color = previous
+			showError(w, "change the list color", err)
+			return
+		}
+		refreshTodoList(a, w)
+	}, w)
+}
+
+func makeSortSelect(a fyne.App, w fyne.Window) fyne.CanvasObject {
+	labels := make([]string, len(sortModes))
+	for i, mode := range sortModes {
+		labels[i] = sortModeLabels[mode]
+	}
+	sortSelect := widget.NewSelect(labels, nil)
+	guiSortSelect = sortSelect
+	sortSelect.SetSelected(sortModeLabels[currentList.SortMode])
+	sortSelect.OnChanged = func(selected string) {
+		mode := sortModeFromLabel(selected)
+		if mode == currentList.SortMode {
+			return
+		}
+		previous := currentList.SortMode
+		currentList.SortMode = mode
+		err := updateListSortMode(currentList)
+		if err != nil {
+			currentList.SortMode = previous
+			sortSelect.SetSelected(sortModeLabels[previous])
+			showError(w, "change the sort order", err)
+			return
+		}
+		refreshTodoList(a, w)
+	}
+	return sortSelect
+}
+
+func makeTodoListContainer(a fyne.App, w fyne.Window) fyne.CanvasObject {
+	if searchQuery != "" {
+		taskList = nil
+		return container.NewVScroll(makeSearchResults(a, w))
 	}
-	return widget.NewLabel("No tasks available")
+	taskList = newTaskListView(a, w)
+	return container.NewStack(taskList.list, container.NewVBox(taskList.empty))
 }
 
-func makeLogo() fyne.CanvasObject {
-	logo := canvas.NewImageFromResource(resourceIconSystemTrayPng)
+func makeLogo(a fyne.App) *canvas.Image {
+	logo := canvas.NewImageFromResource(logoResource(a))
 	logo.FillMode = canvas.ImageFillContain
 	logo.SetMinSize(fyne.NewSize(100, 50))
 	return logo
 }
+
+// logoResource returns the white logo on dark themes and the black one on
+// light themes.
+func logoResource(a fyne.App) fyne.Resource {
+	if themeVariant(a) == theme.VariantLight {
+		return resourcePNGGODOLogoPng
+	}
+	return resourceIconSystemTrayPng
+}
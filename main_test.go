@@ -0,0 +1,33 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// openTestDB opens a new database in a temporary directory for the test.
+func openTestDB(t testing.TB) {
+	t.Helper()
+	err := openDB(filepath.Join(t.TempDir(), "todos.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+		db = nil
+	})
+}
+
+// addTestTodoItem saves a new task with title to the list with listID.
+func addTestTodoItem(t testing.TB, title, listID string) *TodoItem {
+	t.Helper()
+	item, err := newTodoItem(title, "25m", listID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = saveTodoItem(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return item
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The mini timer is a small borderless window showing only the countdown of
+// the task the tray menu follows. Fyne can neither keep a window on top nor
+// place it, so on X11 both are left to wmctrl when it is installed; the
+// window manager's Alt+drag moves the window.
+
+const (
+	miniTimerXKey      = "mini_timer_x"
+	miniTimerYKey      = "mini_timer_y"
+	miniTimerPinnedKey = "mini_timer_pinned"
+)
+
+type miniTimer struct {
+	a    fyne.App
+	tray *trayMenu
+
+	window    fyne.Window
+	title     *widget.Label
+	countdown *canvas.Text
+	progress  *widget.ProgressBar
+
+	toggleButton, stopButton, pinButton *widget.Button
+
+	visible bool
+}
+
+func newMiniTimer(a fyne.App, tray *trayMenu) *miniTimer {
+	return &miniTimer{a: a, tray: tray}
+}
+
+// toggle shows the mini timer or hides it.
+func (m *miniTimer) toggle() {
+	if m.visible {
+		m.hide()
+		return
+	}
+	if m.window == nil {
+		m.window = m.makeWindow()
+	}
+	m.visible = true
+	m.window.Show()
+	m.tray.update()
+	go m.place()
+}
+
+func (m *miniTimer) makeWindow() fyne.Window {
+	var w fyne.Window
+	if drv, ok := m.a.Driver().(desktop.Driver); ok {
+		w = drv.CreateSplashWindow()
+	} else {
+		w = m.a.NewWindow("GoDo")
+	}
+	w.SetCloseIntercept(m.hide)
+
+	m.title = widget.NewLabel("")
+	m.title.Alignment = fyne.TextAlignCenter
+	m.title.Truncation = fyne.TextTruncateEllipsis
+	m.countdown = canvas.NewText(formatTime(0), theme.Color(theme.ColorNameForeground))
+	m.countdown.TextSize = 36
+	m.countdown.TextStyle = fyne.TextStyle{Bold: true, Monospace: true}
+	m.countdown.Alignment = fyne.TextAlignCenter
+	m.progress = widget.NewProgressBar()
+	m.progress.TextFormatter = func() string { return "" }
+
+	m.toggleButton = widget.NewButtonWithIcon("", theme.MediaPlayIcon(), func() {
+		m.tray.toggleTimer()
+	})
+	m.stopButton = widget.NewButtonWithIcon("", theme.MediaStopIcon(), func() {
+		m.tray.stopFollowing()
+	})
+	// The theme has no pin icon; the arrow up stands for on top.
+	m.pinButton = widget.NewButtonWithIcon("", theme.UploadIcon(), func() {
+		pinned := !m.a.Preferences().Bool(miniTimerPinnedKey)
+		m.a.Preferences().SetBool(miniTimerPinnedKey, pinned)
+		m.updatePinButton()
+		go m.place()
+	})
+	m.updatePinButton()
+	closeButton := widget.NewButtonWithIcon("", theme.CancelIcon(), m.hide)
+
+	w.SetContent(container.NewPadded(container.NewVBox(
+		m.title,
+		m.countdown,
+		m.progress,
+		container.NewHBox(m.toggleButton, m.stopButton, layout.NewSpacer(), m.pinButton, closeButton),
+	)))
+	w.Resize(fyne.NewSize(240, 0))
+	return w
+}
+
+func (m *miniTimer) updatePinButton() {
+	if m.a.Preferences().Bool(miniTimerPinnedKey) {
+		m.pinButton.Importance = widget.HighImportance
+	} else {
+		m.pinButton.Importance = widget.MediumImportance
+	}
+	m.pinButton.Refresh()
+}
+
+// hide remembers where the window is and hides it.
+func (m *miniTimer) hide() {
+	m.visible = false
+	go func() {
+		m.savePosition()
+		m.window.Hide()
+	}()
+}
+
+// update shows item, the task the tray menu follows, which may be nil.
+func (m *miniTimer) update(item *TodoItem) {
+	if !m.visible {
+		return
+	}
+	if item == nil {
+		m.title.SetText("No task running")
+		m.countdown.Text = formatTime(0)
+		m.progress.SetValue(0)
+		m.toggleButton.Disable()
+		m.stopButton.Disable()
+	} else {
+		m.title.SetText(item.Title)
+		m.countdown.Text = formatTime(item.RemainingTime)
+		left, _ := trayIconProgress(item)
+		m.progress.SetValue(1 - left)
+		m.toggleButton.Enable()
+		m.stopButton.Enable()
+		if item.Running {
+			m.toggleButton.SetIcon(theme.MediaPauseIcon())
+		} else {
+			m.toggleButton.SetIcon(theme.MediaPlayIcon())
+		}
+	}
+	// The theme may have changed since the window was made.
+	m.countdown.Color = theme.Color(theme.ColorNameForeground)
+	m.countdown.Refresh()
+}
+
+// place moves the window to where it was last and pins it on top if wanted.
+func (m *miniTimer) place() {
+	id := m.windowID()
+	if id == "" {
+		return
+	}
+	prefs := m.a.Preferences()
+	if x, y := prefs.IntWithFallback(miniTimerXKey, -1), prefs.IntWithFallback(miniTimerYKey, -1); x >= 0 && y >= 0 {
+		runWmctrl("-i", "-r", id, "-e", fmt.Sprintf("0,%d,%d,-1,-1", x, y))
+	}
+	action := "remove"
+	if prefs.Bool(miniTimerPinnedKey) {
+		action = "add"
+	}
+	runWmctrl("-i", "-r", id, "-b", action+",above")
+}
+
+func (m *miniTimer) savePosition() {
+	id := m.windowID()
+	if id == "" {
+		return
+	}
+	// wmctrl -l -G lists "<id> <desktop> <x> <y> <width> <height> ...".
+	output, err := exec.Command("wmctrl", "-l", "-G").Output()
+	if err != nil {
+		return
+	}
+	want, _ := strconv.ParseUint(id, 0, 64)
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		if got, _ := strconv.ParseUint(fields[0], 0, 64); got != want {
+			continue
+		}
+		x, errX := strconv.Atoi(fields[2])
+		y, errY := strconv.Atoi(fields[3])
+		if errX == nil && errY == nil {
+			m.a.Preferences().SetInt(miniTimerXKey, x)
+			m.a.Preferences().SetInt(miniTimerYKey, y)
+		}
+	}
+}
+
+// windowID returns the X11 window ID of the mini timer in hex, or "" if the
+// window is not on X11. A window only gets its ID once it is shown, so this
+// waits for it a little.
+func (m *miniTimer) windowID() string {
+	native, ok := m.window.(driver.NativeWindow)
+	if !ok {
+		return ""
+	}
+	for i := 0; i < 20; i++ {
+		var handle uintptr
+		isX11 := false
+		native.RunNative(func(context any) {
+			if x11, ok := context.(driver.X11WindowContext); ok {
+				handle, isX11 = x11.WindowHandle, true
+			}
+		})
+		if !isX11 {
+			return ""
+		}
+		if handle != 0 {
+			return fmt.Sprintf("0x%08x", handle)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return ""
+}
+
+var wmctrlMissing bool
+
+func runWmctrl(args ...string) {
+	if wmctrlMissing {
+		return
+	}
+	err := exec.Command("wmctrl", args...).Run()
+	if errors.Is(err, exec.ErrNotFound) {
+		wmctrlMissing = true
+		slog.Warn("mini timer: install wmctrl to pin the window on top and restore its position")
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"regexp"
+	"strings"
+)
+
+// notesItem is the task whose notes are shown in the side panel, if any.
+var notesItem *TodoItem
+
+// checklistLine matches Markdown task list lines such as "- [ ] milk" or
+// "* [x] eggs". Fyne's Markdown renderer shows these as plain text, so the
+// notes panel renders them as real checkboxes instead.
+var checklistLine = regexp.MustCompile(`^(\s*[-*+] \[)([ xX])(\] )(.*)$`)
+
+func showNotes(a fyne.App, w fyne.Window, item *TodoItem) {
+	if notesItem == item {
+		notesItem = nil
+	} else {
+		notesItem = item
+	}
+	refreshNotesPanel(a, w)
+}
+
+func makeNotesPanel(a fyne.App, w fyne.Window, item *TodoItem) fyne.CanvasObject {
+	body := container.NewStack()
+
+	var showRendered, showEditor func()
+	showRendered = func() {
+		editButton := widget.NewButtonWithIcon("Edit", theme.DocumentCreateIcon(), showEditor)
+		body.Objects = []fyne.CanvasObject{
+			container.NewBorder(nil, editButton, nil, nil, container.NewVScroll(renderNotes(w, item))),
+		}
+		body.Refresh()
+	}
+	showEditor = func() {
+		editor := widget.NewMultiLineEntry()
+		editor.Wrapping = fyne.TextWrapWord
+		editor.SetPlaceHolder("Notes (Markdown)...")
+		editor.SetText(item.Notes)
+		saveButton := widget.NewButtonWithIcon("Save", theme.DocumentSaveIcon(), func() {
+			previous := item.Notes
+			item.Notes = editor.Text
+			err := updateNotes(item)
+			if err != nil {
+				item.Notes = previous
+				showError(w, "save the notes", err)
+				return
+			}
+			showRendered()
+		})
+		body.Objects = []fyne.CanvasObject{container.NewBorder(nil, saveButton, nil, nil, editor)}
+		body.Refresh()
+	}
+
+	if item.Notes == "" {
+		showEditor()
+	} else {
+		showRendered()
+	}
+
+	closeButton := widget.NewButtonWithIcon("", theme.CancelIcon(), func() {
+		showNotes(a, w, item)
+	})
+	header := container.NewBorder(nil, nil, nil, closeButton, widget.NewLabelWithStyle(item.Title, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+	return container.NewBorder(header, nil, nil, nil, body)
+}
+
+// renderNotes renders item's notes as Markdown. Runs of ordinary lines are
+// passed to widget.RichText; checklist lines become checkboxes that update the
+// stored notes when toggled.
+func renderNotes(w fyne.Window, item *TodoItem) fyne.CanvasObject {
+	lines := strings.Split(item.Notes, "\n")
+	out := container.NewVBox()
+
+	var chunk []string
+	flush := func() {
+		if text := strings.TrimSpace(strings.Join(chunk, "\n")); text != "" {
+			richText := widget.NewRichTextFromMarkdown(text)
+			richText.Wrapping = fyne.TextWrapWord
+			out.Add(richText)
+		}
+		chunk = nil
+	}
+
+	for i, line := range lines {
+		match := checklistLine.FindStringSubmatch(line)
+		if match == nil {
+			chunk = append(chunk, line)
+			continue
+		}
+		flush()
+
+		lineIndex := i
+		check := widget.NewCheck(match[4], nil)
+		check.SetChecked(match[2] != " ")
+		check.OnChanged = func(checked bool) {
+			err := toggleChecklistLine(item, lineIndex, checked)
+			if err != nil {
+				showError(w, "save the notes", err)
+			}
+		}
+		out.Add(check)
+	}
+	flush()
+
+	return out
+}
+
+func toggleChecklistLine(item *TodoItem, lineIndex int, checked bool) error {
+	lines := strings.Split(item.Notes, "\n")
+	if lineIndex >= len(lines) {
+		return nil
+	}
+	mark := " "
+	if checked {
+		mark = "x"
+	}
+	lines[lineIndex] = checklistLine.ReplaceAllString(lines[lineIndex], "${1}"+mark+"${3}${4}")
+	previous := item.Notes
+	item.Notes = strings.Join(lines, "\n")
+
+	err := updateNotes(item)
+	if err != nil {
+		item.Notes = previous
+	}
+	return err
+}
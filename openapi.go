@@ -0,0 +1,175 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// openAPIDocument generates an OpenAPI 3.0 description of restRoutes. Schemas
+// are derived from the Go types of the request and response values, so the
+// document cannot drift from the JSON the server actually reads and writes.
+func openAPIDocument(serverURL string) map[string]interface{} {
+	schemas := map[string]interface{}{}
+	schemaFor(reflect.TypeOf(restError{}), schemas)
+	paths := map[string]map[string]interface{}{}
+
+	for _, route := range restRoutes {
+		operation := map[string]interface{}{
+			"summary":  route.summary,
+			"security": []map[string][]string{{"bearerAuth": {}}},
+		}
+
+		var parameters []map[string]interface{}
+		if strings.Contains(route.path, "{id}") {
+			parameters = append(parameters, map[string]interface{}{
+				"name": "id", "in": "path", "required": true,
+				"description": "Task ID or unambiguous ID prefix",
+				"schema":      map[string]string{"type": "string"},
+			})
+		}
+		if route.method == "GET" && (route.path == "/api/tasks" || route.path == "/api/calendar.ics") {
+			parameters = append(parameters,
+				map[string]interface{}{"name": "list", "in": "query", "schema": map[string]string{"type": "string"}})
+		}
+		if route.method == "GET" && route.path == "/api/tasks" {
+			parameters = append(parameters,
+				map[string]interface{}{"name": "all", "in": "query", "schema": map[string]string{"type": "boolean"}})
+		}
+		if route.method == "DELETE" {
+			parameters = append(parameters,
+				map[string]interface{}{"name": "purge", "in": "query", "schema": map[string]string{"type": "boolean"}})
+		}
+		if parameters != nil {
+			operation["parameters"] = parameters
+		}
+
+		if route.request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemaFor(reflect.TypeOf(route.request), schemas)},
+				},
+			}
+		}
+
+		contentType := "application/json"
+		switch route.path {
+		case "/api/events":
+			contentType = "text/event-stream"
+		case "/api/calendar.ics":
+			contentType = "text/calendar"
+		}
+		errorResponse := map[string]interface{}{
+			"description": "Error",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": map[string]string{"$ref": "#/components/schemas/Error"}},
+			},
+		}
+		operation["responses"] = map[string]interface{}{
+			"2XX": map[string]interface{}{
+				"description": "Success",
+				"content": map[string]interface{}{
+					contentType: map[string]interface{}{"schema": schemaFor(reflect.TypeOf(route.response), schemas)},
+				},
+			},
+			"default": errorResponse,
+		}
+
+		if paths[route.path] == nil {
+			paths[route.path] = map[string]interface{}{}
+		}
+		paths[route.path][strings.ToLower(route.method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]string{
+			"title":   "GoDo REST API",
+			"version": "1",
+		},
+		"servers": []map[string]string{{"url": serverURL}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]string{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+// schemaNames gives the structs used by the API their names in the document.
+var schemaNames = map[reflect.Type]string{
+	reflect.TypeOf(todoItemJSON{}):    "Task",
+	reflect.TypeOf(rpcAddParams{}):    "NewTask",
+	reflect.TypeOf(rpcUpdateParams{}): "TaskUpdate",
+	reflect.TypeOf(daemonEvent{}):     "Event",
+	reflect.TypeOf(restError{}):       "Error",
+}
+
+// schemaFor returns the JSON schema for t. Named structs are added to
+// schemas and referenced; with schemas nil they are inlined.
+func schemaFor(t reflect.Type, schemas map[string]interface{}) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		return map[string]string{"type": "string", "format": "date-time"}
+	case t == reflect.TypeOf(TodoItem{}):
+		// TodoItem marshals itself as todoItemJSON.
+		return schemaFor(reflect.TypeOf(todoItemJSON{}), schemas)
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]string{"type": "string"}
+	case reflect.Bool:
+		return map[string]string{"type": "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		return map[string]string{"type": "integer"}
+	case reflect.Float64, reflect.Float32:
+		return map[string]string{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem(), schemas)}
+	case reflect.Struct:
+		name, named := schemaNames[t]
+		if named && schemas != nil {
+			if _, done := schemas[name]; !done {
+				schemas[name] = nil // guards against recursion
+				schemas[name] = structSchema(t, schemas)
+			}
+			return map[string]string{"$ref": "#/components/schemas/" + name}
+		}
+		return structSchema(t, schemas)
+	}
+	return map[string]interface{}{}
+}
+
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemaFor(field.Type, schemas)
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if required != nil {
+		schema["required"] = required
+	}
+	return schema
+}
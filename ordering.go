@@ -0,0 +1,96 @@
+package main
+
+// Manual order is stored as a fractional index: moving a task only rewrites
+// its own position, chosen halfway between its new neighbours. When two
+// neighbours get too close for a float64 midpoint the list is renumbered.
+
+// moveTodoItem moves item by offset places within todoList, which is kept in
+// manual order, and saves the new position immediately. todoList only changes
+// once the position is saved.
+func moveTodoItem(item *TodoItem, offset int) error {
+	todoListLock.Lock()
+	defer todoListLock.Unlock()
+	from := indexOfTodoItem(todoList, item)
+	to := from + offset
+	if from < 0 || to < 0 || to >= len(todoList) || offset == 0 {
+		return nil
+	}
+
+	items := append(todoList[:from:from], todoList[from+1:]...)
+	items = append(items[:to], append([]*TodoItem{item}, items[to:]...)...)
+
+	position, ok := positionAt(items, to)
+	if !ok {
+		positions := make([]float64, len(items))
+		for i, it := range items {
+			positions[i] = it.Position
+		}
+		renumberPositions(items)
+		err := updatePositions(items)
+		if err != nil {
+			for i, it := range items {
+				it.Position = positions[i]
+			}
+			return err
+		}
+		todoList = items
+		return nil
+	}
+	previous := item.Position
+	item.Position = position
+	err := updatePosition(item)
+	if err != nil {
+		item.Position = previous
+		return err
+	}
+	todoList = items
+	return nil
+}
+
+// positionAt returns a position between the neighbours of index, or false if
+// there is no room left between them.
+func positionAt(items []*TodoItem, index int) (float64, bool) {
+	switch {
+	case len(items) == 1:
+		return 1, true
+	case index == 0:
+		return items[1].Position - 1, true
+	case index == len(items)-1:
+		return items[index-1].Position + 1, true
+	}
+	before, after := items[index-1].Position, items[index+1].Position
+	position := before + (after-before)/2
+	if position <= before || position >= after {
+		return 0, false
+	}
+	return position, true
+}
+
+func renumberPositions(items []*TodoItem) {
+	for i, item := range items {
+		item.Position = float64(i + 1)
+	}
+}
+
+func indexOfTodoItem(items []*TodoItem, item *TodoItem) int {
+	for i, it := range items {
+		if it == item {
+			return i
+		}
+	}
+	return -1
+}
+
+// insertByPosition returns items, which are in manual order, with item
+// inserted before the first item with a higher position. items is left as it
+// is.
+func insertByPosition(items []*TodoItem, item *TodoItem) []*TodoItem {
+	i := 0
+	for i < len(items) && items[i].Position <= item.Position {
+		i++
+	}
+	inserted := make([]*TodoItem, 0, len(items)+1)
+	inserted = append(inserted, items[:i]...)
+	inserted = append(inserted, item)
+	return append(inserted, items[i:]...)
+}
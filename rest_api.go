@@ -0,0 +1,308 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The REST API is an optional front end to the daemon for editor plugins and
+// scripts. It only listens on loopback addresses and every request except the
+// OpenAPI document needs the API token, sent as "Authorization: Bearer
+// <token>" or, for EventSource and calendar clients that cannot set headers,
+// as ?token=.
+
+type restServer struct {
+	daemon *daemonServer
+	token  string
+}
+
+type restError struct {
+	Error string `json:"error"`
+}
+
+// restRoute describes one endpoint. The request and response values are only
+// used for their types, to generate the OpenAPI document.
+type restRoute struct {
+	method   string
+	path     string
+	summary  string
+	request  interface{}
+	response interface{}
+	handler  func(s *restServer, w http.ResponseWriter, r *http.Request)
+}
+
+var restRoutes = []restRoute{
+	{"GET", "/api/tasks", "List tasks of a list (?list=<id>) or of all lists (?all=true)", nil, []todoItemJSON{}, (*restServer).listTasks},
+	{"POST", "/api/tasks", "Create a task", rpcAddParams{}, todoItemJSON{}, (*restServer).createTask},
+	{"GET", "/api/tasks/{id}", "Get a task by ID or unambiguous ID prefix", nil, todoItemJSON{}, (*restServer).getTask},
+	{"PATCH", "/api/tasks/{id}", "Change the fields given in the body", rpcUpdateParams{}, todoItemJSON{}, (*restServer).updateTask},
+	{"DELETE", "/api/tasks/{id}", "Archive a task, or delete it with ?purge=true", nil, todoItemJSON{}, (*restServer).removeTask},
+	{"POST", "/api/tasks/{id}/start", "Start the task's timer", nil, todoItemJSON{}, restTimerAction("start")},
+	{"POST", "/api/tasks/{id}/stop", "Stop the task's timer, keeping the remaining time", nil, todoItemJSON{}, restTimerAction("stop")},
+	{"POST", "/api/tasks/{id}/reset", "Reset the task's timer to its full duration", nil, todoItemJSON{}, restTimerAction("reset")},
+	{"POST", "/api/tasks/{id}/done", "Mark the task as done", nil, todoItemJSON{}, restTimerAction("done")},
+	{"GET", "/api/events", "Server-Sent Events stream of task and timer events", nil, daemonEvent{}, (*restServer).events},
+	{"GET", "/api/calendar.ics", "iCalendar feed of the tasks of all lists, or of ?list=<id>", nil, "", (*restServer).calendarFeed},
+}
+
+const openAPIPath = "/api/openapi.json"
+
+func startRESTServer(daemonServer *daemonServer, addr string, out io.Writer) (*http.Server, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("the REST API only listens on loopback addresses, not %s", host)
+	}
+
+	token, tokenSource, err := apiToken()
+	if err != nil {
+		return nil, err
+	}
+	s := &restServer{daemon: daemonServer, token: token}
+
+	mux := http.NewServeMux()
+	for _, route := range restRoutes {
+		handler := route.handler
+		mux.HandleFunc(route.method+" "+route.path, func(w http.ResponseWriter, r *http.Request) {
+			if !s.authorized(r) {
+				writeRESTError(w, http.StatusUnauthorized, errors.New("missing or invalid API token"))
+				return
+			}
+			handler(s, w, r)
+		})
+	}
+	mux.HandleFunc("GET "+openAPIPath, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, openAPIDocument("http://"+addr))
+	})
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	httpServer := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		err := httpServer.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintln(os.Stderr, "godo: REST API:", err)
+		}
+	}()
+
+	fmt.Fprintf(out, "REST API listening on http://%s (token: %s, spec: %s)\n", addr, tokenSource, openAPIPath)
+	return httpServer, nil
+}
+
+// apiToken returns the API token from $GODO_API_TOKEN or the token file in
+// the user's config directory, creating the file on first use. The second
+// result says where the token came from.
+func apiToken() (string, string, error) {
+	if token := os.Getenv("GODO_API_TOKEN"); token != "" {
+		return token, "$GODO_API_TOKEN", nil
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", "", err
+	}
+	path := filepath.Join(configDir, "godo", "api-token")
+	data, err := os.ReadFile(path)
+	if err == nil && strings.TrimSpace(string(data)) != "" {
+		return strings.TrimSpace(string(data)), path, nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", "", err
+	}
+
+	secret := make([]byte, 32)
+	_, err = rand.Read(secret)
+	if err != nil {
+		return "", "", err
+	}
+	token := hex.EncodeToString(secret)
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return "", "", err
+	}
+	return token, path, os.WriteFile(path, []byte(token+"\n"), 0600)
+}
+
+func (s *restServer) authorized(r *http.Request) bool {
+	token := r.URL.Query().Get("token")
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		token = strings.TrimPrefix(header, "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+func (s *restServer) listTasks(w http.ResponseWriter, r *http.Request) {
+	params := rpcListParams{ListID: r.URL.Query().Get("list"), All: r.URL.Query().Get("all") == "true"}
+	items, err := s.daemon.list(params)
+	if err != nil {
+		writeRESTError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (s *restServer) createTask(w http.ResponseWriter, r *http.Request) {
+	var params rpcAddParams
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		writeRESTError(w, http.StatusBadRequest, err)
+		return
+	}
+	item, err := s.daemon.add(params)
+	if err != nil {
+		writeRESTError(w, restStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, item)
+}
+
+func (s *restServer) getTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.taskID(w, r)
+	if !ok {
+		return
+	}
+	item, err := s.daemon.get(id)
+	if err != nil {
+		writeRESTError(w, restStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+func (s *restServer) updateTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.taskID(w, r)
+	if !ok {
+		return
+	}
+	var params rpcUpdateParams
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		writeRESTError(w, http.StatusBadRequest, err)
+		return
+	}
+	params.ID = id
+	item, err := s.daemon.update(params)
+	if err != nil {
+		writeRESTError(w, restStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+func (s *restServer) removeTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.taskID(w, r)
+	if !ok {
+		return
+	}
+	item, err := s.daemon.itemAction("remove", rpcItemParams{ID: id, Purge: r.URL.Query().Get("purge") == "true"})
+	if err != nil {
+		writeRESTError(w, restStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+func restTimerAction(action string) func(s *restServer, w http.ResponseWriter, r *http.Request) {
+	return func(s *restServer, w http.ResponseWriter, r *http.Request) {
+		id, ok := s.taskID(w, r)
+		if !ok {
+			return
+		}
+		item, err := s.daemon.itemAction(action, rpcItemParams{ID: id})
+		if err != nil {
+			writeRESTError(w, restStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	}
+}
+
+// events streams daemon events as Server-Sent Events named after the event
+// type, with the task as JSON data.
+func (s *restServer) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeRESTError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+
+	events := s.daemon.subscribe()
+	defer s.daemon.unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event.Item)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// taskID resolves the {id} path parameter, which may be an ID prefix, to a
+// full task ID. It writes an error response and returns false on failure.
+func (s *restServer) taskID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	prefix := strings.ToLower(r.PathValue("id"))
+	items, err := findTodoItems(prefix)
+	switch {
+	case err != nil:
+		writeRESTError(w, http.StatusInternalServerError, err)
+	case len(items) == 0:
+		writeRESTError(w, http.StatusNotFound, fmt.Errorf("no task with ID %s", prefix))
+	case len(items) > 1:
+		writeRESTError(w, http.StatusBadRequest, fmt.Errorf("ID %s is ambiguous, it matches %d tasks", prefix, len(items)))
+	default:
+		return items[0].ID.String(), true
+	}
+	return "", false
+}
+
+func restStatus(err error) int {
+	var e *rpcError
+	if errors.As(err, &e) && e.Code == rpcInvalidParams {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func writeRESTError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, restError{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
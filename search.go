@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"log/slog"
+	"strings"
+)
+
+// Full-text search uses an FTS5 index kept in sync with the todos table by
+// triggers. go-sqlite3 only compiles FTS5 in with the sqlite_fts5 build tag,
+// which the Makefile sets. A build without it warns at startup and searches
+// with LIKE, which matches substrings and does not rank results.
+var ftsEnabled bool
+
+var searchQuery string
+var searchIncludeCompleted bool
+
+func initSearchIndex() error {
+	// The index is only built when it or its triggers are new: a build
+	// without FTS5 drops the triggers, so writes made by it are missing.
+	var existing int
+	err := db.QueryRow(`SELECT count(*) FROM sqlite_master WHERE name IN ('todos_fts', 'todos_fts_insert', 'todos_fts_delete', 'todos_fts_update')`).Scan(&existing)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS todos_fts USING fts5(task, notes, tags, content='todos', content_rowid='rowid')`)
+	if err != nil {
+		ftsEnabled = false
+		slog.Warn("full-text search unavailable, build with -tags sqlite_fts5", "error", err)
+		// Triggers left behind by an FTS5-enabled build would make every
+		// write to todos fail.
+		_, err = db.Exec(`
+		DROP TRIGGER IF EXISTS todos_fts_insert;
+		DROP TRIGGER IF EXISTS todos_fts_delete;
+		DROP TRIGGER IF EXISTS todos_fts_update;`)
+		return err
+	}
+
+	_, err = db.Exec(`
+	CREATE TRIGGER IF NOT EXISTS todos_fts_insert AFTER INSERT ON todos BEGIN
+		INSERT INTO todos_fts (rowid, task, notes, tags) VALUES (new.rowid, new.task, new.notes, new.tags);
+	END;
+	CREATE TRIGGER IF NOT EXISTS todos_fts_delete AFTER DELETE ON todos BEGIN
+		INSERT INTO todos_fts (todos_fts, rowid, task, notes, tags) VALUES ('delete', old.rowid, old.task, old.notes, old.tags);
+	END;
+	CREATE TRIGGER IF NOT EXISTS todos_fts_update AFTER UPDATE OF task, notes, tags ON todos BEGIN
+		INSERT INTO todos_fts (todos_fts, rowid, task, notes, tags) VALUES ('delete', old.rowid, old.task, old.notes, old.tags);
+		INSERT INTO todos_fts (rowid, task, notes, tags) VALUES (new.rowid, new.task, new.notes, new.tags);
+	END;`)
+	if err != nil {
+		return err
+	}
+
+	if existing < 4 {
+		_, err = db.Exec(`INSERT INTO todos_fts (todos_fts) VALUES ('rebuild')`)
+		if err != nil {
+			return err
+		}
+	}
+	ftsEnabled = true
+	return nil
+}
+
+// searchTodoItems returns tasks from all lists whose title, notes or tags
+// match every word of query as a prefix, best matches first. Completed and
+// archived tasks are only included on request.
+func searchTodoItems(query string, includeCompleted bool) ([]*TodoItem, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	completedFilter := ` AND todos.completed = 0 AND todos.archived_at = ''`
+	if includeCompleted {
+		completedFilter = ``
+	}
+
+	if ftsEnabled {
+		rows, err := db.Query(`SELECT `+todoColumns+` FROM todos_fts JOIN todos ON todos.rowid = todos_fts.rowid
+			WHERE todos_fts MATCH ?`+completedFilter+` ORDER BY todos_fts.rank`, matchExpression(terms))
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		return scanTodoItems(rows)
+	}
+
+	where := make([]string, len(terms))
+	args := make([]interface{}, 0, 3*len(terms))
+	for i, term := range terms {
+		where[i] = `(todos.task LIKE ? ESCAPE '\' OR todos.notes LIKE ? ESCAPE '\' OR todos.tags LIKE ? ESCAPE '\')`
+		pattern := "%" + likeEscaper.Replace(term) + "%"
+		args = append(args, pattern, pattern, pattern)
+	}
+	rows, err := db.Query(`SELECT `+todoColumns+` FROM todos WHERE `+strings.Join(where, ` AND `)+completedFilter+
+		` ORDER BY todos.list_id, todos.position`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanTodoItems(rows)
+}
+
+// likeEscaper escapes the LIKE wildcards in a search term, so that "50%" only
+// matches itself.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func searchTerms(query string) []string {
+	return strings.Fields(strings.ToLower(query))
+}
+
+// matchExpression turns user input into an FTS5 query. Every term is quoted so
+// that punctuation typed by the user is never parsed as query syntax.
+func matchExpression(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"*`
+	}
+	return strings.Join(quoted, " ")
+}
+
+// parseTags and formatTags convert between the space separated tags column
+// and a slice of tag names without the leading '#'.
+func parseTags(s string) []string {
+	var tags []string
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' }) {
+		tag := strings.TrimPrefix(field, "#")
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func formatTags(tags []string) string {
+	return strings.Join(tags, " ")
+}
+
+func tagsLabel(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return "#" + strings.Join(tags, " #")
+}
+
+// highlightSegments splits text into rich text segments with every occurrence
+// of a search term shown in bold.
+func highlightSegments(text string, terms []string) []widget.RichTextSegment {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		// Lower-casing changed byte offsets; show the text unhighlighted.
+		return []widget.RichTextSegment{&widget.TextSegment{Text: text, Style: widget.RichTextStyleInline}}
+	}
+
+	matched := make([]bool, len(text))
+	for _, term := range terms {
+		for start := 0; ; {
+			i := strings.Index(lower[start:], term)
+			if i < 0 {
+				break
+			}
+			for j := start + i; j < start+i+len(term); j++ {
+				matched[j] = true
+			}
+			start += i + len(term)
+		}
+	}
+
+	var segments []widget.RichTextSegment
+	for start := 0; start < len(text); {
+		end := start
+		for end < len(text) && matched[end] == matched[start] {
+			end++
+		}
+		style := widget.RichTextStyleInline
+		if matched[start] {
+			style = widget.RichTextStyleStrong
+		}
+		segments = append(segments, &widget.TextSegment{Text: text[start:end], Style: style})
+		start = end
+	}
+	return segments
+}
+
+// searchEntry is the search field shown, which the search shortcut focuses.
+var searchEntry *widget.Entry
+
+func makeSearchBar(a fyne.App, w fyne.Window, listArea *fyne.Container) fyne.CanvasObject {
+	refresh := func() {
+		listArea.Objects = []fyne.CanvasObject{makeTodoListContainer(a, w)}
+		listArea.Refresh()
+	}
+
+	searchEntry = widget.NewEntry()
+	searchEntry.SetPlaceHolder("Search tasks...")
+	searchEntry.SetText(searchQuery)
+	searchEntry.OnChanged = func(text string) {
+		searchQuery = text
+		refresh()
+	}
+
+	includeCompletedCheck := widget.NewCheck("Include done & archived", func(checked bool) {
+		searchIncludeCompleted = checked
+		if searchQuery != "" {
+			refresh()
+		}
+	})
+	includeCompletedCheck.SetChecked(searchIncludeCompleted)
+
+	return container.NewBorder(nil, nil, widget.NewIcon(theme.SearchIcon()), includeCompletedCheck, searchEntry)
+}
+
+func makeSearchResults(a fyne.App, w fyne.Window) fyne.CanvasObject {
+	items, err := searchTodoItems(searchQuery, searchIncludeCompleted)
+	if err != nil {
+		slog.Error("search failed", "error", err)
+		return widget.NewLabel("Search failed")
+	}
+	if len(items) == 0 {
+		return widget.NewLabel("No matching tasks")
+	}
+
+	terms := searchTerms(searchQuery)
+	results := make([]fyne.CanvasObject, len(items))
+	for i, item := range items {
+		list := findTodoList(item.ListID)
+		title := widget.NewRichText(highlightSegments(item.Title, terms)...)
+		tags := widget.NewRichText(highlightSegments(tagsLabel(item.Tags), terms)...)
+
+		doneIcon := widget.NewIcon(theme.CheckButtonIcon())
+		if item.Completed {
+			doneIcon.SetResource(theme.CheckButtonCheckedIcon())
+		}
+
+		var showButton *widget.Button
+		if item.ArchivedAt.IsZero() {
+			showButton = widget.NewButtonWithIcon(list.Name, theme.NavigateNextIcon(), func() {
+				if list != currentList {
+					err := switchList(list)
+					if err != nil {
+						showError(w, "open the list", err)
+					}
+				}
+				// Clearing the search shows the list again.
+				searchEntry.SetText("")
+				refreshLists(a, w)
+			})
+		} else {
+			showButton = widget.NewButtonWithIcon("Archive", theme.HistoryIcon(), func() {
+				showArchiveWindow(a, w)
+			})
+		}
+
+		results[i] = container.NewHBox(newListStripe(listColor(item.ListID)), doneIcon, title, tags, showButton)
+	}
+	return container.NewVBox(results...)
+}
+
+func findTodoList(id string) *TodoList {
+	for _, list := range todoLists {
+		if list.ID == id {
+			return list
+		}
+	}
+	return currentList
+}
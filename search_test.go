@@ -0,0 +1,50 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSearchIndexRebuiltAfterBuildWithoutFTS(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.db")
+	err := openDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+		db = nil
+	})
+	if !ftsEnabled {
+		t.Skip("built without the sqlite_fts5 tag")
+	}
+	lists, err := getTodoLists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := addTestTodoItem(t, "Write report", lists[0].ID)
+
+	// A build without FTS5 drops the triggers, then changes the task.
+	_, err = db.Exec(`DROP TRIGGER todos_fts_insert; DROP TRIGGER todos_fts_delete; DROP TRIGGER todos_fts_update`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`UPDATE todos SET task = 'Review budget' WHERE id = ?`, item.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = openDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for query, want := range map[string]int{"budget": 1, "report": 0} {
+		items, err := searchTodoItems(query, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != want {
+			t.Errorf("search for %q: got %d tasks, want %d", query, len(items), want)
+		}
+	}
+}
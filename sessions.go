@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Every run of a timer, from start until it is paused, stopped or finishes,
+// is recorded as a session. Sessions can be exported for time trackers and
+// invoicing: as Timewarrior data, or as CSV files for the Toggl Track and
+// Clockify importers.
+
+// timerSession is a recorded session together with the task it belongs to.
+type timerSession struct {
+	TaskID    uuid.UUID
+	Title     string
+	List      string
+	Tags      []string
+	StartedAt time.Time
+	EndedAt   time.Time
+}
+
+type sessionFormat string
+
+const (
+	sessionTimewarrior sessionFormat = "timewarrior"
+	sessionToggl       sessionFormat = "toggl"
+	sessionClockify    sessionFormat = "clockify"
+)
+
+var sessionFormats = []sessionFormat{sessionTimewarrior, sessionToggl, sessionClockify}
+
+func parseSessionFormat(s string) (sessionFormat, error) {
+	for _, format := range sessionFormats {
+		if string(format) == strings.ToLower(s) {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("unknown session format %q, want timewarrior, toggl or clockify", s)
+}
+
+const (
+	timewarriorLayout = "20060102T150405Z"
+	clockLayout       = "15:04:05"
+)
+
+// exportTimerSessions writes sessions to out in format. The CSV formats need
+// the email address of the user the time entries belong to.
+func exportTimerSessions(out io.Writer, format sessionFormat, sessions []*timerSession, email string) error {
+	switch format {
+	case sessionTimewarrior:
+		w := bufio.NewWriter(out)
+		for _, session := range sessions {
+			w.WriteString(timewarriorLine(session) + "\n")
+		}
+		return w.Flush()
+	case sessionToggl:
+		return writeTogglCSV(out, sessions, email)
+	case sessionClockify:
+		return writeClockifyCSV(out, sessions, email)
+	}
+	return fmt.Errorf("unknown session format %q", format)
+}
+
+// filterSessionsByTag keeps the sessions of tasks tagged tag.
+func filterSessionsByTag(sessions []*timerSession, tag string) []*timerSession {
+	tag = strings.TrimPrefix(tag, "#")
+	var filtered []*timerSession
+	for _, session := range sessions {
+		if slices.ContainsFunc(session.Tags, func(t string) bool { return strings.EqualFold(t, tag) }) {
+			filtered = append(filtered, session)
+		}
+	}
+	return filtered
+}
+
+// timewarriorLine formats a session as an interval of a Timewarrior data
+// file, tagged with the task title, its list and its tags.
+func timewarriorLine(session *timerSession) string {
+	tags := []string{quoteTimewarriorTag(session.Title)}
+	if session.List != "" {
+		tags = append(tags, quoteTimewarriorTag(session.List))
+	}
+	for _, tag := range session.Tags {
+		tags = append(tags, quoteTimewarriorTag(tag))
+	}
+	return fmt.Sprintf("inc %s - %s # %s",
+		session.StartedAt.UTC().Format(timewarriorLayout),
+		session.EndedAt.UTC().Format(timewarriorLayout),
+		strings.Join(tags, " "))
+}
+
+func quoteTimewarriorTag(tag string) string {
+	if strings.ContainsAny(tag, " \t\"#") {
+		return `"` + strings.ReplaceAll(tag, `"`, `\"`) + `"`
+	}
+	return tag
+}
+
+// writeTimewarriorFiles writes sessions into the monthly data files of a
+// Timewarrior data directory, such as ~/.timewarrior/data. Intervals already
+// in a file are not added twice, so exporting overlapping ranges is safe.
+// Timewarrior rebuilds its tag database on "timew tags" once the files change.
+func writeTimewarriorFiles(dir string, sessions []*timerSession) error {
+	months := map[string][]string{}
+	var order []string
+	for _, session := range sessions {
+		month := session.StartedAt.UTC().Format("2006-01")
+		if _, ok := months[month]; !ok {
+			order = append(order, month)
+		}
+		months[month] = append(months[month], timewarriorLine(session))
+	}
+
+	for _, month := range order {
+		path := filepath.Join(dir, month+".data")
+		existing, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		lines := strings.Split(strings.TrimRight(string(existing), "\n"), "\n")
+		if len(existing) == 0 {
+			lines = nil
+		}
+		for _, line := range months[month] {
+			if !slices.Contains(lines, line) {
+				lines = append(lines, line)
+			}
+		}
+		// Timewarrior expects the intervals of a file in chronological order,
+		// which sorting the lines gives thanks to the fixed layout.
+		slices.Sort(lines)
+		err = os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTogglCSV writes sessions in the CSV layout of the Toggl Track
+// importer, with the list as project and the task title as description.
+func writeTogglCSV(out io.Writer, sessions []*timerSession, email string) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"Email", "Project", "Description", "Start date", "Start time", "End date", "End time", "Duration", "Tags"})
+	for _, session := range sessions {
+		w.Write([]string{
+			email,
+			session.List,
+			session.Title,
+			session.StartedAt.Format(dueDateLayout),
+			session.StartedAt.Format(clockLayout),
+			session.EndedAt.Format(dueDateLayout),
+			session.EndedAt.Format(clockLayout),
+			formatTime(session.EndedAt.Sub(session.StartedAt)),
+			strings.Join(session.Tags, ", "),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// writeClockifyCSV writes sessions in the CSV layout of the Clockify
+// importer, with the list as project and the task title as description.
+func writeClockifyCSV(out io.Writer, sessions []*timerSession, email string) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"Project", "Description", "Email", "Tags", "Start Date", "Start Time", "End Date", "End Time", "Duration (h)"})
+	for _, session := range sessions {
+		w.Write([]string{
+			session.List,
+			session.Title,
+			email,
+			strings.Join(session.Tags, ", "),
+			session.StartedAt.Format(dueDateLayout),
+			session.StartedAt.Format(clockLayout),
+			session.EndedAt.Format(dueDateLayout),
+			session.EndedAt.Format(clockLayout),
+			formatTime(session.EndedAt.Sub(session.StartedAt)),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"image/color"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The settings window edits the appearance, timer, window and database
+// sections of the config file and applies them as soon as they are saved.
+
+const defaultSound = "sounds/finished.wav"
+
+var defaultDurationPresets = []string{"10s", "1m", "15m", "30m", "1h", "3h"}
+
+var themeLabels = map[string]string{
+	themeSystem: "Follow the system",
+	themeDark:   "Dark",
+	themeLight:  "Light",
+}
+
+var textSizes = []string{"Default", "10", "11", "12", "13", "14", "16", "18", "20", "24"}
+
+var closeLabels = map[string]string{
+	"":         "Hide to the system tray",
+	closeQuits: "Quit",
+}
+
+// newTaskDuration returns the timer duration of new tasks.
+func newTaskDuration() string {
+	if currentConfig.Timer.Duration != "" {
+		return currentConfig.Timer.Duration
+	}
+	return defaultDuration
+}
+
+// durationPresets returns the durations offered for new tasks, which always
+// include the default.
+func durationPresets() []string {
+	presets := currentConfig.Timer.Presets
+	if len(presets) == 0 {
+		presets = defaultDurationPresets
+	}
+	if !slices.Contains(presets, newTaskDuration()) {
+		presets = append([]string{newTaskDuration()}, presets...)
+	}
+	return presets
+}
+
+func soundPath(timer timerConfig) string {
+	if timer.Sound == "" {
+		return defaultSound
+	}
+	path, err := expandHome(timer.Sound)
+	if err != nil {
+		return timer.Sound
+	}
+	return path
+}
+
+func windowSize(window windowConfig) fyne.Size {
+	size := fyne.NewSize(400, 200)
+	if window.Width > 0 {
+		size.Width = window.Width
+	}
+	if window.Height > 0 {
+		size.Height = window.Height
+	}
+	return size
+}
+
+// labelKey returns the key of labels whose label is label.
+func labelKey(labels map[string]string, label string) string {
+	for key, l := range labels {
+		if l == label {
+			return key
+		}
+	}
+	return ""
+}
+
+// labelsInOrder returns the labels of keys.
+func labelsInOrder(labels map[string]string, keys ...string) []string {
+	var inOrder []string
+	for _, key := range keys {
+		inOrder = append(inOrder, labels[key])
+	}
+	return inOrder
+}
+
+func showSettingsWindow(a fyne.App, w fyne.Window) {
+	settingsWindow := a.NewWindow("Settings")
+	settingsWindow.Resize(fyne.NewSize(500, 0))
+	timer := currentConfig.Timer
+
+	themeSelect := widget.NewSelect(labelsInOrder(themeLabels, themeSystem, themeDark, themeLight), nil)
+	themeSelect.SetSelected(themeLabels[currentConfig.Appearance.Theme])
+	if themeSelect.Selected == "" {
+		themeSelect.SetSelected(themeLabels[themeSystem])
+	}
+
+	accent := currentConfig.Appearance.Accent
+	accentSwatch := canvas.NewRectangle(color.Transparent)
+	accentSwatch.SetMinSize(fyne.NewSize(32, 0))
+	showAccent := func() {
+		accentSwatch.FillColor = theme.Color(theme.ColorNamePrimary)
+		if c, err := parseHexColor(accent); err == nil {
+			accentSwatch.FillColor = c
+		}
+		accentSwatch.Refresh()
+	}
+	showAccent()
+	accentButton := widget.NewButton("Choose…", func() {
+		showColorDialog("Accent color", "Default", func(hex string) {
+			accent = hex
+			showAccent()
+		}, settingsWindow)
+	})
+	highContrastCheck := widget.NewCheck("High contrast", nil)
+	highContrastCheck.SetChecked(currentConfig.Appearance.HighContrast)
+
+	textSizeSelect := widget.NewSelect(textSizes, nil)
+	textSizeSelect.SetSelected(textSizes[0])
+	if size := currentConfig.Appearance.TextSize; size > 0 {
+		textSizeSelect.SetSelected(strconv.FormatFloat(float64(size), 'f', -1, 32))
+	}
+
+	durationEntry := widget.NewEntry()
+	durationEntry.SetText(newTaskDuration())
+
+	presetsEntry := widget.NewEntry()
+	presetsEntry.SetPlaceHolder(strings.Join(defaultDurationPresets, " "))
+	presetsEntry.SetText(strings.Join(timer.Presets, " "))
+
+	soundEntry := widget.NewEntry()
+	soundEntry.SetPlaceHolder(defaultSound)
+	soundEntry.SetText(timer.Sound)
+	testSoundButton := widget.NewButtonWithIcon("", theme.MediaPlayIcon(), func() {
+		path := soundPath(timerConfig{Sound: strings.TrimSpace(soundEntry.Text)})
+		_, err := os.Stat(path)
+		if err != nil {
+			showError(settingsWindow, "find the sound file", err)
+			return
+		}
+		go func() {
+			err := playSoundFile(path)
+			if err != nil {
+				showError(settingsWindow, "play the sound", err)
+			}
+		}()
+	})
+	muteCheck := widget.NewCheck("Mute", nil)
+	muteCheck.SetChecked(timer.Mute)
+	notifyCheck := widget.NewCheck("Show a notification when a timer finishes", nil)
+	notifyCheck.SetChecked(timer.Notify)
+
+	closeSelect := widget.NewSelect(labelsInOrder(closeLabels, "", closeQuits), nil)
+	closeSelect.SetSelected(closeLabels[currentConfig.Window.Close])
+	if closeSelect.Selected == "" {
+		closeSelect.SetSelected(closeLabels[""])
+	}
+
+	databaseEntry := widget.NewEntry()
+	databaseEntry.SetPlaceHolder(defaultDBPath)
+	databaseEntry.SetText(currentConfig.Database.Path)
+
+	form := container.New(layout.NewFormLayout(),
+		widget.NewLabel("Theme"), themeSelect,
+		widget.NewLabel("Accent color"), container.NewBorder(nil, nil, accentSwatch, nil, accentButton),
+		widget.NewLabel("Contrast"), highContrastCheck,
+		widget.NewLabel("Text size"), textSizeSelect,
+		widget.NewLabel("Default duration"), durationEntry,
+		widget.NewLabel("Duration presets"), presetsEntry,
+		widget.NewLabel("Sound"), container.NewBorder(nil, nil, nil, container.NewHBox(testSoundButton, muteCheck), soundEntry),
+		widget.NewLabel("Notifications"), notifyCheck,
+		widget.NewLabel("Closing the window"), closeSelect,
+		widget.NewLabel("Database"), databaseEntry,
+	)
+
+	saveButton := widget.NewButtonWithIcon("Save", theme.DocumentSaveIcon(), func() {
+		// Sections the window does not edit are kept as they are in the file.
+		config, err := loadConfig()
+		if err != nil {
+			showError(settingsWindow, "load the settings", err)
+			return
+		}
+		config.Appearance.Theme = labelKey(themeLabels, themeSelect.Selected)
+		if config.Appearance.Theme == themeSystem {
+			config.Appearance.Theme = ""
+		}
+		config.Appearance.Accent = accent
+		config.Appearance.HighContrast = highContrastCheck.Checked
+		config.Appearance.TextSize = 0
+		if size, err := strconv.ParseFloat(textSizeSelect.Selected, 32); err == nil {
+			config.Appearance.TextSize = float32(size)
+		}
+
+		config.Timer.Duration = strings.TrimSpace(durationEntry.Text)
+		if config.Timer.Duration == "" {
+			config.Timer.Duration = defaultDuration
+		}
+		config.Timer.Presets = strings.Fields(presetsEntry.Text)
+		for _, duration := range append([]string{config.Timer.Duration}, config.Timer.Presets...) {
+			_, err := time.ParseDuration(duration)
+			if err != nil {
+				showInvalidInput(settingsWindow, fmt.Sprintf("invalid duration %q", duration))
+				return
+			}
+		}
+		if config.Timer.Duration == defaultDuration {
+			config.Timer.Duration = ""
+		}
+		config.Timer.Sound = strings.TrimSpace(soundEntry.Text)
+		if !muteCheck.Checked {
+			_, err := os.Stat(soundPath(config.Timer))
+			if err != nil {
+				showError(settingsWindow, "find the sound file", err)
+				return
+			}
+		}
+		config.Timer.Mute = muteCheck.Checked
+		config.Timer.Notify = notifyCheck.Checked
+		config.Window.Close = labelKey(closeLabels, closeSelect.Selected)
+		config.Database.Path = strings.TrimSpace(databaseEntry.Text)
+
+		if config.Database.Path != currentConfig.Database.Path {
+			err = switchDatabase(a, w, config.Database.Path)
+			if err != nil {
+				showError(settingsWindow, "switch the database", err)
+				return
+			}
+		}
+		err = saveConfig(config)
+		if err != nil {
+			showError(settingsWindow, "save the settings", err)
+			return
+		}
+		currentConfig = config
+		a.Settings().SetTheme(newGoDoTheme(config.Appearance))
+		settingsWindow.Close()
+	})
+	saveButton.Importance = widget.HighImportance
+	cancelButton := widget.NewButton("Cancel", settingsWindow.Close)
+
+	settingsWindow.SetContent(container.NewVBox(form, container.NewHBox(layout.NewSpacer(), cancelButton, saveButton)))
+	settingsWindow.Show()
+}
+
+// switchDatabase opens the database at path in place of the current one and
+// shows its lists. Timers running in this process are stopped first, which
+// stores their remaining time in the database they belong to. The vault and
+// git history are reopened with the new database, as they would be when
+// GoDo starts with it. If the database cannot be opened or read, GoDo stays
+// with the current one.
+func switchDatabase(a fyne.App, w fyne.Window, path string) error {
+	if daemon != nil {
+		return errors.New("the daemon has the database open; stop it to switch databases")
+	}
+	for _, item := range currentTodoItems() {
+		logTimerError(item.StopTimer())
+	}
+	closeMirrors()
+
+	previous, err := useDB(path)
+	if err != nil {
+		return errors.Join(err, reopenMirrors(a, w))
+	}
+	// Opening the vault may add tasks from its files, so the lists are read
+	// after.
+	mirrorErr := openMirrors(currentConfig)
+	lists, err := getTodoLists()
+	var items []*TodoItem
+	if err == nil {
+		items, err = loadTodoItems(lists[0].ID)
+	}
+	if err != nil {
+		closeMirrors()
+		_ = db.Close()
+		db = previous
+		return errors.Join(err, reopenMirrors(a, w))
+	}
+	if previous != nil {
+		_ = previous.Close()
+	}
+
+	todoLists = lists
+	currentList = todoLists[0]
+	changeTodoItems(func([]*TodoItem) []*TodoItem {
+		return items
+	})
+	selectedItem = nil
+	watchVault(a, w)
+	if mirrorErr != nil {
+		// The switch is done; only the mirrors are missing.
+		showError(w, "open the vault or git history", mirrorErr)
+	}
+	refreshLists(a, w)
+	return nil
+}
+
+// reopenMirrors opens the vault and git history for the database in use.
+func reopenMirrors(a fyne.App, w fyne.Window) error {
+	err := openMirrors(currentConfig)
+	watchVault(a, w)
+	return err
+}
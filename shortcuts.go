@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+	"log/slog"
+	"strings"
+)
+
+// Keyboard shortcuts act on the selected task, which Alt+Up and Alt+Down
+// move through the list. Their key combinations can be changed in the
+// cheat sheet, which stores them in the [shortcuts] section of the config
+// file. Fyne only runs shortcuts with Ctrl, Alt or Super held, and not while
+// a text field has the focus.
+
+type shortcutAction struct {
+	name    string
+	label   string
+	binding string
+	run     func(a fyne.App, w fyne.Window)
+}
+
+// shortcutActions returns the actions in cheat sheet order. It is a function
+// because the actions refer back to the GUI, which refers to the cheat sheet.
+func shortcutActions() []shortcutAction {
+	return []shortcutAction{
+		{"new_task", "New task", "Ctrl+N", showNewTodoWindow},
+		{"quick_add", "Quick add", "Ctrl+Shift+N", showQuickAddWindow},
+		{"toggle_timer", "Start or pause the selected task", "Ctrl+Space", func(a fyne.App, w fyne.Window) {
+			withSelectedItem(func(item *TodoItem) {
+				toggleTimer(w, item)
+			})
+		}},
+		{"reset_timer", "Reset the selected task", "Ctrl+R", func(a fyne.App, w fyne.Window) {
+			withSelectedItem(func(item *TodoItem) {
+				err := resetTimer(item)
+				if err != nil {
+					showError(w, "reset the timer", err)
+				}
+			})
+		}},
+		{"toggle_done", "Mark the selected task done or not done", "Ctrl+D", func(a fyne.App, w fyne.Window) {
+			withSelectedItem(func(item *TodoItem) {
+				item.Completed = !item.Completed
+				err := updateCompleted(item)
+				if err != nil {
+					item.Completed = !item.Completed
+					showError(w, "update the task", err)
+					return
+				}
+				refreshTodoList(a, w)
+			})
+		}},
+		{"delete", "Archive the selected task", "Ctrl+Delete", archiveSelectedItem},
+		{"search", "Search", "Ctrl+F", func(a fyne.App, w fyne.Window) {
+			if searchEntry != nil {
+				w.Canvas().Focus(searchEntry)
+			}
+		}},
+		{"select_up", "Select the task above", "Alt+Up", func(a fyne.App, w fyne.Window) {
+			moveSelection(a, w, -1)
+		}},
+		{"select_down", "Select the task below", "Alt+Down", func(a fyne.App, w fyne.Window) {
+			moveSelection(a, w, 1)
+		}},
+		{"settings", "Settings", "Ctrl+,", showSettingsWindow},
+		{"shortcuts", "Keyboard shortcuts", "Ctrl+/", showShortcutsDialog},
+	}
+}
+
+// selectedItem is the task keyboard shortcuts act on, nil if none is.
+var selectedItem *TodoItem
+
+// shortcutBindings are the key combinations in use, by action name.
+var shortcutBindings = map[string]*desktop.CustomShortcut{}
+
+var shortcutModifiers = []struct {
+	name     string
+	modifier fyne.KeyModifier
+}{
+	{"Ctrl", fyne.KeyModifierControl},
+	{"Shift", fyne.KeyModifierShift},
+	{"Alt", fyne.KeyModifierAlt},
+	{"Super", fyne.KeyModifierSuper},
+}
+
+// parseShortcut parses a key combination such as "Ctrl+Shift+N".
+func parseShortcut(s string) (*desktop.CustomShortcut, error) {
+	parts := strings.Split(strings.TrimSpace(s), "+")
+	if strings.HasSuffix(s, "++") {
+		parts = append(parts[:len(parts)-2], "+")
+	}
+	shortcut := &desktop.CustomShortcut{}
+	for _, part := range parts[:len(parts)-1] {
+		found := false
+		for _, m := range shortcutModifiers {
+			if strings.EqualFold(strings.TrimSpace(part), m.name) {
+				shortcut.Modifier |= m.modifier
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown modifier %q in %q", part, s)
+		}
+	}
+	key := strings.TrimSpace(parts[len(parts)-1])
+	if len(key) == 1 {
+		key = strings.ToUpper(key)
+	} else if len(key) > 1 {
+		key = strings.ToUpper(key[:1]) + key[1:]
+	}
+	shortcut.KeyName = fyne.KeyName(key)
+	if key == "" || shortcut.Modifier == 0 || shortcut.Modifier == fyne.KeyModifierShift {
+		return nil, fmt.Errorf("%q needs a key and Ctrl, Alt or Super", s)
+	}
+	return shortcut, nil
+}
+
+func formatShortcut(shortcut *desktop.CustomShortcut) string {
+	var parts []string
+	for _, m := range shortcutModifiers {
+		if shortcut.Modifier&m.modifier != 0 {
+			parts = append(parts, m.name)
+		}
+	}
+	return strings.Join(append(parts, string(shortcut.KeyName)), "+")
+}
+
+// addShortcuts registers the shortcuts on the canvas of w, with the key
+// combinations of overrides replacing the defaults.
+func addShortcuts(a fyne.App, w fyne.Window, overrides map[string]string) {
+	for _, shortcut := range shortcutBindings {
+		w.Canvas().RemoveShortcut(shortcut)
+	}
+	shortcutBindings = map[string]*desktop.CustomShortcut{}
+
+	for _, action := range shortcutActions() {
+		binding := action.binding
+		if override, ok := overrides[action.name]; ok {
+			binding = override
+		}
+		shortcut, err := parseShortcut(binding)
+		if err != nil {
+			slog.Warn("invalid shortcut", "error", err)
+			shortcut, _ = parseShortcut(action.binding)
+		}
+		shortcutBindings[action.name] = shortcut
+		w.Canvas().AddShortcut(shortcut, func(run func(fyne.App, fyne.Window)) func(fyne.Shortcut) {
+			return func(fyne.Shortcut) {
+				run(a, w)
+			}
+		}(action.run))
+	}
+}
+
+// visibleTodoItems returns the tasks of the current list in display order.
+func visibleTodoItems() []*TodoItem {
+	items := append([]*TodoItem(nil), currentTodoItems()...)
+	sortTodoItems(items, currentList.SortMode)
+	return items
+}
+
+func withSelectedItem(f func(item *TodoItem)) {
+	if selectedItem != nil && indexOfTodoItem(currentTodoItems(), selectedItem) >= 0 {
+		f(selectedItem)
+	}
+}
+
+// moveSelection selects the task offset rows away from the selected one, or
+// the first task if none is selected.
+func moveSelection(a fyne.App, w fyne.Window, offset int) {
+	if taskList == nil {
+		return
+	}
+	items := visibleTodoItems()
+	if len(items) == 0 {
+		return
+	}
+	i := indexOfTodoItem(items, selectedItem)
+	if i < 0 {
+		i = 0
+	} else {
+		i = min(max(i+offset, 0), len(items)-1)
+	}
+	taskList.list.Select(i)
+}
+
+// archiveSelectedItem archives the selected task and selects the next one.
+func archiveSelectedItem(a fyne.App, w fyne.Window) {
+	withSelectedItem(func(item *TodoItem) {
+		items := visibleTodoItems()
+		i := indexOfTodoItem(items, item)
+		err := stopTimer(item)
+		if err == nil {
+			err = removeTodoItem(item, false)
+		}
+		if err != nil {
+			showError(w, "archive the task", err)
+			return
+		}
+
+		changeTodoItems(func(items []*TodoItem) []*TodoItem {
+			return removeTodoItemFrom(items, item)
+		})
+		items = removeTodoItemFrom(items, item)
+		selectedItem = nil
+		if len(items) > 0 {
+			selectedItem = items[min(i, len(items)-1)]
+		}
+		refreshTodoList(a, w)
+	})
+}
+
+func removeTodoItemFrom(items []*TodoItem, item *TodoItem) []*TodoItem {
+	var remaining []*TodoItem
+	for _, other := range items {
+		if other != item {
+			remaining = append(remaining, other)
+		}
+	}
+	return remaining
+}
+
+// showShortcutsDialog shows the shortcuts and lets the user change them.
+func showShortcutsDialog(a fyne.App, w fyne.Window) {
+	actions := shortcutActions()
+	entries := make([]*widget.Entry, len(actions))
+	form := container.New(layout.NewFormLayout())
+	for i, action := range actions {
+		entries[i] = widget.NewEntry()
+		entries[i].SetText(formatShortcut(shortcutBindings[action.name]))
+		form.Add(widget.NewLabel(action.label))
+		form.Add(entries[i])
+	}
+
+	dialog.ShowCustomConfirm("Keyboard shortcuts", "Save", "Close", form, func(save bool) {
+		if !save {
+			return
+		}
+		overrides := map[string]string{}
+		used := map[string]string{}
+		for i, action := range actions {
+			shortcut, err := parseShortcut(entries[i].Text)
+			if err != nil {
+				showInvalidInput(w, err.Error())
+				return
+			}
+			binding := formatShortcut(shortcut)
+			if other, ok := used[binding]; ok {
+				showInvalidInput(w, fmt.Sprintf("%s is used for both %q and %q", binding, other, action.label))
+				return
+			}
+			used[binding] = action.label
+			if binding != action.binding {
+				overrides[action.name] = binding
+			}
+		}
+
+		config, err := loadConfig()
+		if err == nil {
+			config.Shortcuts = overrides
+			err = saveConfig(config)
+		}
+		if err != nil {
+			showError(w, "save the shortcuts", err)
+			return
+		}
+		addShortcuts(a, w, overrides)
+	}, w)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+type sortMode string
+
+const (
+	sortByPriority      sortMode = "priority"
+	sortByDueDate       sortMode = "due_date"
+	sortByCreated       sortMode = "created"
+	sortByRemainingTime sortMode = "remaining_time"
+	sortByManual        sortMode = "manual"
+)
+
+var sortModes = []sortMode{sortByPriority, sortByDueDate, sortByCreated, sortByRemainingTime, sortByManual}
+
+var sortModeLabels = map[sortMode]string{
+	sortByPriority:      "Priority",
+	sortByDueDate:       "Due date",
+	sortByCreated:       "Created",
+	sortByRemainingTime: "Remaining time",
+	sortByManual:        "Manual",
+}
+
+var priorityLabels = []string{"P1", "P2", "P3", "P4"}
+
+const defaultPriority = 4
+
+func priorityLabel(priority int) string {
+	return fmt.Sprintf("P%d", priority)
+}
+
+func parsePriority(label string) int {
+	for i, l := range priorityLabels {
+		if l == label {
+			return i + 1
+		}
+	}
+	return defaultPriority
+}
+
+func sortModeFromLabel(label string) sortMode {
+	for mode, l := range sortModeLabels {
+		if l == label {
+			return mode
+		}
+	}
+	return sortByManual
+}
+
+// sortTodoItems orders items in place. The sort is stable, so items that
+// compare equal keep the manual order they were loaded in.
+func sortTodoItems(items []*TodoItem, mode sortMode) {
+	var less func(a, b *TodoItem) bool
+	switch mode {
+	case sortByPriority:
+		less = func(a, b *TodoItem) bool { return a.Priority < b.Priority }
+	case sortByDueDate:
+		less = func(a, b *TodoItem) bool {
+			// Tasks without a due date go last.
+			if a.DueDate.IsZero() || b.DueDate.IsZero() {
+				return !a.DueDate.IsZero() && b.DueDate.IsZero()
+			}
+			return a.DueDate.Before(b.DueDate)
+		}
+	case sortByCreated:
+		less = func(a, b *TodoItem) bool { return a.CreatedAt.Before(b.CreatedAt) }
+	case sortByRemainingTime:
+		less = func(a, b *TodoItem) bool { return a.RemainingTime < b.RemainingTime }
+	default:
+		return
+	}
+	sort.SliceStable(items, func(i, j int) bool { return less(items[i], items[j]) })
+}
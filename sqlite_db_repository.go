@@ -2,22 +2,77 @@ package main
 
 import (
 	"database/sql"
-	"fyne.io/fyne/v2/widget"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
-	_ "github.com/mattn/go-sqlite3"
-	"log"
+	"github.com/mattn/go-sqlite3"
+	"log/slog"
 	"time"
 )
 
 var db *sql.DB
 
-func initDB() {
-	var err error
-	db, err = sql.Open("sqlite3", "./todos.db")
+const defaultListID = "inbox"
+
+const defaultDBPath = "./todos.db"
+
+// The daemon, the CLI and the GUI may write to the database at the same
+// time. SQLite waits up to busyTimeout milliseconds for the others, and
+// writes that still find the database busy are retried busyRetries times
+// after a growing pause. Transactions take the write lock when they begin,
+// where SQLite can wait for it, rather than failing when they first write.
+const (
+	busyTimeout = 5000
+	busyRetries = 4
+)
+
+func initDB(path string) {
+	err := openDB(path)
+	if err != nil {
+		fatal(err)
+	}
+}
+
+// openDB opens the database at path, creating and migrating its tables, and
+// uses it instead of the database open before, which it closes.
+func openDB(path string) error {
+	previous, err := useDB(path)
+	if err != nil {
+		return err
+	}
+	if previous != nil {
+		_ = previous.Close()
+	}
+	return nil
+}
+
+// useDB is openDB, but returns the database open before instead of closing
+// it, so that the caller may go back to it. On errors db is left as it was.
+func useDB(path string) (*sql.DB, error) {
+	if path == "" {
+		path = defaultDBPath
+	}
+	path, err := expandHome(path)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	opened, err := sql.Open("sqlite3", fmt.Sprintf("%s?_busy_timeout=%d&_txlock=immediate", path, busyTimeout))
+	if err != nil {
+		return nil, err
 	}
 
+	previous := db
+	db = opened
+	err = createTables()
+	if err != nil {
+		db = previous
+		_ = opened.Close()
+		return nil, err
+	}
+	return previous, nil
+}
+
+func createTables() error {
 	createTableQuery := `
 	CREATE TABLE IF NOT EXISTS todos (
 		id TEXT PRIMARY KEY,
@@ -27,33 +82,245 @@ func initDB() {
 		completed BOOLEAN
 	);`
 
-	_, err = db.Exec(createTableQuery)
+	_, err := db.Exec(createTableQuery)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	return migrateDB()
 }
 
-func saveTodoItem(item *TodoItem) error {
-	_, err := db.Exec(`INSERT INTO todos (id, task, duration, remaining_time, completed) VALUES (?, ?, ?, ?, ?)`,
-		item.ID.String(), item.Task.Text, item.Duration.Text, item.RemainingTime.String(), item.Checkbox.Checked)
+// migrateDB brings databases created by older versions up to the current
+// schema. Every step must be safe to run on an already migrated database.
+func migrateDB() error {
+	columns := []struct{ name, definition string }{
+		{"priority", "INTEGER NOT NULL DEFAULT 4"},
+		{"due_at", "TEXT NOT NULL DEFAULT ''"},
+		{"created_at", "TEXT NOT NULL DEFAULT ''"},
+		{"list_id", "TEXT NOT NULL DEFAULT '" + defaultListID + "'"},
+		{"position", "REAL"},
+		{"notes", "TEXT NOT NULL DEFAULT ''"},
+		{"tags", "TEXT NOT NULL DEFAULT ''"},
+		{"archived_at", "TEXT NOT NULL DEFAULT ''"},
+		{"recurrence", "TEXT NOT NULL DEFAULT ''"},
+	}
+	for _, column := range columns {
+		err := addColumnIfMissing("todos", column.name, column.definition)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Rows from before manual ordering keep their insertion order.
+	_, err := db.Exec(`UPDATE todos SET position = rowid WHERE position IS NULL`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS lists (
+		id TEXT PRIMARY KEY,
+		name TEXT,
+		sort_mode TEXT
+	);`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`INSERT OR IGNORE INTO lists (id, name, sort_mode) VALUES (?, ?, ?)`,
+		defaultListID, "Inbox", string(sortByManual))
+	if err != nil {
+		return err
+	}
+	err = addColumnIfMissing("lists", "color", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id TEXT NOT NULL,
+		started_at TEXT NOT NULL,
+		ended_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS sessions_started_at ON sessions (started_at);`)
+	if err != nil {
+		return err
+	}
+
+	// What CalDAV sync last saw of each collection and its members.
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS caldav_collections (
+		url TEXT PRIMARY KEY,
+		sync_token TEXT NOT NULL DEFAULT ''
+	);
+	CREATE TABLE IF NOT EXISTS caldav_resources (
+		collection TEXT NOT NULL,
+		href TEXT NOT NULL,
+		task_id TEXT NOT NULL DEFAULT '',
+		etag TEXT NOT NULL DEFAULT '',
+		hash TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (collection, href)
+	);`)
+	if err != nil {
+		return err
+	}
+	err = addColumnIfMissing("caldav_resources", "data", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		return err
+	}
+
+	// The hash of each vault file as last synced, shared by all processes,
+	// tells edits made by other programs apart from files GoDo wrote itself.
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS vault_files (path TEXT PRIMARY KEY, hash TEXT NOT NULL)`)
+	if err != nil {
+		return err
+	}
+
+	return initSearchIndex()
+}
+
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
+
+// retryBusy runs write, again while it fails because the database is busy.
+func retryBusy(write func() error) error {
+	err := write()
+	for i := 0; i < busyRetries && isBusy(err); i++ {
+		slog.Warn("database busy, retrying", "error", err)
+		time.Sleep(time.Duration(100<<i) * time.Millisecond)
+		err = write()
+	}
+	return err
+}
+
+// execWrite is db.Exec retried while the database is busy.
+func execWrite(query string, args ...any) (sql.Result, error) {
+	var result sql.Result
+	err := retryBusy(func() error {
+		var err error
+		result, err = db.Exec(query, args...)
+		return err
+	})
+	return result, err
+}
+
+func addColumnIfMissing(table, column, definition string) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition)
+	return err
+}
+
+// todosChanged is called after every write that changes tasks or lists, but
+// not after timer updates of the remaining time. Mirrors of the database such
+// as the Markdown vault hook in here.
+var todosChanged = func() {}
+
+// notifyChanged calls todosChanged if the write that returned err succeeded.
+func notifyChanged(err error) error {
+	if err == nil {
+		todosChanged()
+	}
 	return err
 }
 
-func getTodoItems() ([]*TodoItem, error) {
-	rows, err := db.Query(`SELECT id, task, duration, remaining_time, completed FROM todos`)
+func saveTodoItem(item *TodoItem) error {
+	err := db.QueryRow(`SELECT COALESCE(MAX(position), 0) + 1 FROM todos WHERE list_id = ?`, item.ListID).Scan(&item.Position)
+	if err != nil {
+		return err
+	}
+
+	_, err = execWrite(`INSERT INTO todos (id, task, duration, remaining_time, completed, priority, due_at, created_at, list_id, position, notes, tags, recurrence) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		item.ID.String(), item.Title, item.Duration, item.RemainingTime.String(), item.Completed,
+		item.Priority, formatStoredTime(item.DueDate), formatStoredTime(item.CreatedAt), item.ListID, item.Position,
+		item.Notes, formatTags(item.Tags), item.Recurrence)
+	return notifyChanged(err)
+}
+
+// replaceTodoItem stores every field of item, inserting it if it does not
+// exist yet. Syncing uses it to apply tasks merged from elsewhere.
+func replaceTodoItem(item *TodoItem) error {
+	_, err := execWrite(`INSERT INTO todos (id, task, duration, remaining_time, completed, priority, due_at, created_at, list_id, position, notes, tags, archived_at, recurrence)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET task = excluded.task, duration = excluded.duration, remaining_time = excluded.remaining_time,
+		completed = excluded.completed, priority = excluded.priority, due_at = excluded.due_at, created_at = excluded.created_at,
+		list_id = excluded.list_id, position = excluded.position, notes = excluded.notes, tags = excluded.tags,
+		archived_at = excluded.archived_at, recurrence = excluded.recurrence`,
+		item.ID.String(), item.Title, item.Duration, item.RemainingTime.String(), item.Completed,
+		item.Priority, formatStoredTime(item.DueDate), formatStoredTime(item.CreatedAt), item.ListID, item.Position,
+		item.Notes, formatTags(item.Tags), formatStoredTime(item.ArchivedAt), item.Recurrence)
+	return notifyChanged(err)
+}
+
+// todoColumns lists the columns read by scanTodoItems, in scan order.
+const todoColumns = `todos.id, todos.task, todos.duration, todos.remaining_time, todos.completed, todos.priority,
+	todos.due_at, todos.created_at, todos.list_id, todos.position, todos.notes, todos.tags, todos.archived_at,
+	todos.recurrence`
+
+func getTodoItems(listID string) ([]*TodoItem, error) {
+	rows, err := db.Query(`SELECT `+todoColumns+` FROM todos WHERE list_id = ? AND archived_at = '' ORDER BY position, rowid`, listID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTodoItems(rows)
+}
+
+// getAllTodoItems returns the unarchived tasks of every list.
+func getAllTodoItems() ([]*TodoItem, error) {
+	rows, err := db.Query(`SELECT ` + todoColumns + ` FROM todos WHERE archived_at = '' ORDER BY list_id, position, rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTodoItems(rows)
+}
+
+// findTodoItems returns every task, archived or not, whose ID starts with prefix.
+func findTodoItems(prefix string) ([]*TodoItem, error) {
+	rows, err := db.Query(`SELECT `+todoColumns+` FROM todos WHERE substr(id, 1, ?) = ? ORDER BY rowid`, len(prefix), prefix)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	return scanTodoItems(rows)
+}
+
+func scanTodoItems(rows *sql.Rows) ([]*TodoItem, error) {
 	var items []*TodoItem
 	for rows.Next() {
 
 		var id uuid.UUID
-		var task, duration, remainingTimeStr string
+		var task, duration, remainingTimeStr, dueAtStr, createdAtStr, itemListID, notes, tags, archivedAtStr, recurrence string
 		var completed bool
+		var priority int
+		var position float64
 
-		err = rows.Scan(&id, &task, &duration, &remainingTimeStr, &completed)
+		err := rows.Scan(&id, &task, &duration, &remainingTimeStr, &completed, &priority, &dueAtStr, &createdAtStr, &itemListID, &position, &notes, &tags, &archivedAtStr, &recurrence)
 		if err != nil {
 			return nil, err
 		}
@@ -65,26 +332,276 @@ func getTodoItems() ([]*TodoItem, error) {
 
 		item := &TodoItem{
 			ID:            id,
-			Checkbox:      widget.NewCheck("", nil),
-			Task:          widget.NewLabel(task),
-			Duration:      widget.NewLabel(duration),
-			Timer:         widget.NewLabel(formatTime(remainingTime)),
+			Title:         task,
+			Duration:      duration,
+			Completed:     completed,
 			RemainingTime: remainingTime,
 			Running:       false,
 			Done:          make(chan bool),
+			Priority:      priority,
+			DueDate:       parseStoredTime(dueAtStr),
+			CreatedAt:     parseStoredTime(createdAtStr),
+			ListID:        itemListID,
+			Position:      position,
+			Notes:         notes,
+			Tags:          parseTags(tags),
+			ArchivedAt:    parseStoredTime(archivedAtStr),
+			Recurrence:    recurrence,
 		}
-		item.Checkbox.SetChecked(completed)
 		items = append(items, item)
 	}
-	return items, nil
+	return items, rows.Err()
 }
 
 func deleteTodoItem(item *TodoItem) error {
-	_, err := db.Exec(`DELETE FROM todos WHERE id = ?`, item.ID.String())
-	return err
+	_, err := execWrite(`DELETE FROM todos WHERE id = ?`, item.ID.String())
+	return notifyChanged(err)
 }
 
 func updateRemainingTime(item *TodoItem) error {
-	_, err := db.Exec(`UPDATE todos SET remaining_time = ? WHERE id = ?`, item.RemainingTime.String(), item.ID.String())
+	_, err := execWrite(`UPDATE todos SET remaining_time = ? WHERE id = ?`, item.RemainingTime.String(), item.ID.String())
+	return err
+}
+
+// saveTimerSession records that the timer of the task with id ran from start
+// to end. Times are stored in UTC so that they sort as text.
+func saveTimerSession(id uuid.UUID, start, end time.Time) error {
+	_, err := execWrite(`INSERT INTO sessions (task_id, started_at, ended_at) VALUES (?, ?, ?)`,
+		id.String(), formatStoredTime(start.UTC()), formatStoredTime(end.UTC()))
+	return err
+}
+
+// getTimerSessions returns the sessions started in [from, to) of tasks in the
+// list with listID, or in all lists if listID is empty, oldest first. A zero
+// from or to leaves that end of the range open.
+func getTimerSessions(from, to time.Time, listID string) ([]*timerSession, error) {
+	query := `SELECT sessions.task_id, sessions.started_at, sessions.ended_at, todos.task, todos.tags, COALESCE(lists.name, '')
+		FROM sessions JOIN todos ON todos.id = sessions.task_id LEFT JOIN lists ON lists.id = todos.list_id
+		WHERE (? = '' OR sessions.started_at >= ?) AND (? = '' OR sessions.started_at < ?) AND (? = '' OR todos.list_id = ?)
+		ORDER BY sessions.started_at`
+	fromStr, toStr := formatStoredTime(from.UTC()), formatStoredTime(to.UTC())
+	rows, err := db.Query(query, fromStr, fromStr, toStr, toStr, listID, listID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*timerSession
+	for rows.Next() {
+		var taskID uuid.UUID
+		var startedAt, endedAt, title, tags, list string
+		err = rows.Scan(&taskID, &startedAt, &endedAt, &title, &tags, &list)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &timerSession{
+			TaskID:    taskID,
+			Title:     title,
+			List:      list,
+			Tags:      parseTags(tags),
+			StartedAt: parseStoredTime(startedAt).Local(),
+			EndedAt:   parseStoredTime(endedAt).Local(),
+		})
+	}
+	return sessions, rows.Err()
+}
+
+// getRecentTodoItems returns up to limit open tasks, most recently timed
+// first.
+func getRecentTodoItems(limit int) ([]*TodoItem, error) {
+	rows, err := db.Query(`SELECT `+todoColumns+` FROM todos
+		JOIN (SELECT task_id, MAX(ended_at) AS ended_at FROM sessions GROUP BY task_id) recent ON recent.task_id = todos.id
+		WHERE todos.archived_at = '' AND NOT todos.completed ORDER BY recent.ended_at DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTodoItems(rows)
+}
+
+// updateTodoItem stores the editable fields of an existing task.
+func updateTodoItem(item *TodoItem) error {
+	_, err := execWrite(`UPDATE todos SET task = ?, duration = ?, completed = ?, priority = ?, due_at = ?, tags = ?, notes = ?, list_id = ?, recurrence = ? WHERE id = ?`,
+		item.Title, item.Duration, item.Completed, item.Priority, formatStoredTime(item.DueDate), formatTags(item.Tags),
+		item.Notes, item.ListID, item.Recurrence, item.ID.String())
+	return notifyChanged(err)
+}
+
+func updateCompleted(item *TodoItem) error {
+	_, err := execWrite(`UPDATE todos SET completed = ? WHERE id = ?`, item.Completed, item.ID.String())
+	return notifyChanged(err)
+}
+
+func updateNotes(item *TodoItem) error {
+	_, err := execWrite(`UPDATE todos SET notes = ? WHERE id = ?`, item.Notes, item.ID.String())
+	return notifyChanged(err)
+}
+
+func updatePosition(item *TodoItem) error {
+	_, err := execWrite(`UPDATE todos SET position = ? WHERE id = ?`, item.Position, item.ID.String())
+	return notifyChanged(err)
+}
+
+// updatePositions stores the positions of several items in one transaction.
+func updatePositions(items []*TodoItem) error {
+	err := retryBusy(func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			_, err = tx.Exec(`UPDATE todos SET position = ? WHERE id = ?`, item.Position, item.ID.String())
+			if err != nil {
+				_ = tx.Rollback()
+				return err
+			}
+		}
+		return tx.Commit()
+	})
+	return notifyChanged(err)
+}
+
+// getArchivedTodoItems returns archived tasks from all lists, most recently
+// archived first.
+func getArchivedTodoItems() ([]*TodoItem, error) {
+	rows, err := db.Query(`SELECT ` + todoColumns + ` FROM todos WHERE archived_at != '' ORDER BY archived_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTodoItems(rows)
+}
+
+func archiveTodoItem(item *TodoItem) error {
+	item.ArchivedAt = time.Now()
+	_, err := execWrite(`UPDATE todos SET archived_at = ? WHERE id = ?`, formatStoredTime(item.ArchivedAt), item.ID.String())
+	return notifyChanged(err)
+}
+
+func restoreTodoItem(item *TodoItem) error {
+	item.ArchivedAt = time.Time{}
+	_, err := execWrite(`UPDATE todos SET archived_at = '' WHERE id = ?`, item.ID.String())
+	return notifyChanged(err)
+}
+
+// purgeArchivedTodoItems permanently deletes tasks archived before cutoff and
+// returns how many were removed.
+func purgeArchivedTodoItems(cutoff time.Time) (int64, error) {
+	var purged int64
+	rows, err := db.Query(`SELECT id, archived_at FROM todos WHERE archived_at != ''`)
+	if err != nil {
+		return 0, err
+	}
+	var expired []string
+	for rows.Next() {
+		var id, archivedAt string
+		err = rows.Scan(&id, &archivedAt)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if parseStoredTime(archivedAt).Before(cutoff) {
+			expired = append(expired, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range expired {
+		result, err := execWrite(`DELETE FROM todos WHERE id = ?`, id)
+		if err != nil {
+			return purged, err
+		}
+		n, _ := result.RowsAffected()
+		purged += n
+	}
+	if purged > 0 {
+		todosChanged()
+	}
+	return purged, nil
+}
+
+func getTodoLists() ([]*TodoList, error) {
+	rows, err := db.Query(`SELECT id, name, sort_mode, color FROM lists ORDER BY rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lists []*TodoList
+	for rows.Next() {
+		list := &TodoList{}
+		var mode string
+		err = rows.Scan(&list.ID, &list.Name, &mode, &list.Color)
+		if err != nil {
+			return nil, err
+		}
+		list.SortMode = sortMode(mode)
+		lists = append(lists, list)
+	}
+	return lists, rows.Err()
+}
+
+func saveTodoList(list *TodoList) error {
+	_, err := execWrite(`INSERT INTO lists (id, name, sort_mode, color) VALUES (?, ?, ?, ?)`, list.ID, list.Name, string(list.SortMode), list.Color)
+	return notifyChanged(err)
+}
+
+// replaceTodoList stores list, inserting it if it does not exist yet.
+func replaceTodoList(list *TodoList) error {
+	_, err := execWrite(`INSERT INTO lists (id, name, sort_mode, color) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET name = excluded.name, sort_mode = excluded.sort_mode, color = excluded.color`,
+		list.ID, list.Name, string(list.SortMode), list.Color)
+	return notifyChanged(err)
+}
+
+// saveMissingTodoLists stores the lists that do not exist yet and leaves the
+// others as they are.
+func saveMissingTodoLists(lists []*TodoList) error {
+	existing, err := getTodoLists()
+	if err != nil {
+		return err
+	}
+	known := map[string]bool{}
+	for _, list := range existing {
+		known[list.ID] = true
+	}
+	for _, list := range lists {
+		if known[list.ID] {
+			continue
+		}
+		err = saveTodoList(list)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func updateListSortMode(list *TodoList) error {
+	_, err := execWrite(`UPDATE lists SET sort_mode = ? WHERE id = ?`, string(list.SortMode), list.ID)
 	return err
 }
+
+func updateListColor(list *TodoList) error {
+	_, err := execWrite(`UPDATE lists SET color = ? WHERE id = ?`, list.Color, list.ID)
+	return notifyChanged(err)
+}
+
+// formatStoredTime and parseStoredTime convert between time.Time and the TEXT
+// columns used for dates. The zero time is stored as an empty string.
+func formatStoredTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func parseStoredTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"image/color"
+	"sync"
+	"time"
+)
+
+// The tasks of the current list are shown in a widget.List, which only
+// creates rows for the tasks on screen and reuses them while scrolling.
+// Changes to todoList are shown with refreshTodoList, and timer ticks refresh
+// the row of their task only.
+
+// taskListView is the list showing the current list's tasks.
+type taskListView struct {
+	list  *widget.List
+	empty *widget.Label
+
+	// lock guards items, which timer goroutines look rows up in.
+	lock sync.Mutex
+	// items are the tasks of todoList in display order.
+	items []*TodoItem
+}
+
+// taskList is the list shown, nil while search results are shown instead.
+var taskList *taskListView
+
+func newTaskListView(a fyne.App, w fyne.Window) *taskListView {
+	v := &taskListView{empty: widget.NewLabel("No tasks available")}
+	v.list = widget.NewList(
+		func() int {
+			v.lock.Lock()
+			defer v.lock.Unlock()
+			return len(v.items)
+		},
+		func() fyne.CanvasObject {
+			return newTaskRow()
+		},
+		func(id widget.ListItemID, object fyne.CanvasObject) {
+			v.lock.Lock()
+			if id >= len(v.items) {
+				v.lock.Unlock()
+				return
+			}
+			item := v.items[id]
+			v.lock.Unlock()
+			object.(*taskRow).bind(a, w, item)
+		},
+	)
+	v.list.OnSelected = func(id widget.ListItemID) {
+		v.lock.Lock()
+		defer v.lock.Unlock()
+		if id < len(v.items) {
+			selectedItem = v.items[id]
+		}
+	}
+	v.list.OnUnselected = func(id widget.ListItemID) {
+		v.lock.Lock()
+		defer v.lock.Unlock()
+		if id < len(v.items) && v.items[id] == selectedItem {
+			selectedItem = nil
+		}
+	}
+	v.refresh()
+	return v
+}
+
+// refresh sorts todoList into display order and updates the rows shown.
+func (v *taskListView) refresh() {
+	v.lock.Lock()
+	v.items = visibleTodoItems()
+	selected := indexOfTodoItem(v.items, selectedItem)
+	empty := len(v.items) == 0
+	v.lock.Unlock()
+
+	if empty {
+		v.empty.Show()
+	} else {
+		v.empty.Hide()
+	}
+	v.list.Refresh()
+	if selected >= 0 {
+		v.list.Select(selected)
+	} else {
+		v.list.UnselectAll()
+	}
+}
+
+// refreshItem updates the row of item, if it is shown.
+func (v *taskListView) refreshItem(item *TodoItem) {
+	v.lock.Lock()
+	i := indexOfTodoItem(v.items, item)
+	v.lock.Unlock()
+	if i >= 0 {
+		v.list.RefreshItem(i)
+	}
+}
+
+// refreshTodoList shows changes to todoList, rebuilding the list area only if
+// search results are shown instead of the list. The notes panel is closed if
+// its task left the list.
+func refreshTodoList(a fyne.App, w fyne.Window) {
+	if taskList == nil {
+		guiListArea.Objects = []fyne.CanvasObject{makeTodoListContainer(a, w)}
+		guiListArea.Refresh()
+	} else {
+		taskList.refresh()
+	}
+	if notesItem != nil && indexOfTodoItem(currentTodoItems(), notesItem) < 0 {
+		refreshNotesPanel(a, w)
+	}
+}
+
+// refreshTimer is installed as todoItemChanged in the GUI.
+func refreshTimer(item *TodoItem) {
+	if taskList != nil {
+		taskList.refreshItem(item)
+	}
+}
+
+// taskState is the state a task row shows in color.
+type taskState int
+
+const (
+	taskIdle taskState = iota
+	taskRunning
+	taskPaused
+	taskFinished
+	taskOverdue
+)
+
+// taskStateStyles are the theme color of the progress bar and the importance
+// of the timer label by state.
+var taskStateStyles = map[taskState]struct {
+	color      fyne.ThemeColorName
+	importance widget.Importance
+}{
+	taskIdle:     {theme.ColorNameDisabled, widget.MediumImportance},
+	taskRunning:  {theme.ColorNamePrimary, widget.HighImportance},
+	taskPaused:   {theme.ColorNameWarning, widget.WarningImportance},
+	taskFinished: {theme.ColorNameSuccess, widget.SuccessImportance},
+	taskOverdue:  {theme.ColorNameError, widget.DangerImportance},
+}
+
+// taskStateOf returns the state of item. A running or finished timer is shown
+// rather than the task being overdue.
+func taskStateOf(item *TodoItem) taskState {
+	switch {
+	case item.Running:
+		return taskRunning
+	case item.RemainingTime <= 0:
+		return taskFinished
+	case isOverdue(item):
+		return taskOverdue
+	case timerStarted(item):
+		return taskPaused
+	}
+	return taskIdle
+}
+
+// isOverdue reports whether item is not done and was due before today.
+func isOverdue(item *TodoItem) bool {
+	if item.Completed || item.DueDate.IsZero() {
+		return false
+	}
+	year, month, day := time.Now().Date()
+	return item.DueDate.Before(time.Date(year, month, day, 0, 0, 0, 0, time.Local))
+}
+
+// timerStarted reports whether item's timer has run since it was last reset.
+func timerStarted(item *TodoItem) bool {
+	duration, err := time.ParseDuration(item.Duration)
+	return err == nil && item.RemainingTime != duration
+}
+
+// taskRow is a reusable row of the task list. bind points it at a task.
+type taskRow struct {
+	widget.BaseWidget
+
+	stripe                                      *canvas.Rectangle
+	check                                       *widget.Check
+	priority, title, tags, due, duration, timer *widget.Label
+	strike                                      *canvas.Line
+	titleBox, box                               *fyne.Container
+	progress                                    *taskProgress
+	toggle, reset, notes, moveUp, moveDown      *widget.Button
+}
+
+func newTaskRow() *taskRow {
+	r := &taskRow{
+		stripe:   newListStripe(color.Transparent),
+		check:    widget.NewCheck("", nil),
+		priority: widget.NewLabel(""),
+		title:    widget.NewLabel(""),
+		tags:     widget.NewLabel(""),
+		due:      widget.NewLabel(""),
+		duration: widget.NewLabel(""),
+		timer:    widget.NewLabel(""),
+		strike:   canvas.NewLine(color.Transparent),
+		progress: newTaskProgress(),
+		toggle:   widget.NewButtonWithIcon("Start", theme.MediaPlayIcon(), nil),
+		reset:    widget.NewButtonWithIcon("Reset", theme.ViewRefreshIcon(), nil),
+		notes:    widget.NewButtonWithIcon("", theme.DocumentIcon(), nil),
+		moveUp:   widget.NewButtonWithIcon("", theme.MoveUpIcon(), nil),
+		moveDown: widget.NewButtonWithIcon("", theme.MoveDownIcon(), nil),
+	}
+	r.titleBox = container.New(&strikeLayout{label: r.title}, r.title, r.strike)
+	r.box = container.NewHBox(
+		r.stripe,
+		r.check,
+		r.priority,
+		r.titleBox,
+		r.tags,
+		r.due,
+		r.duration,
+		r.progress,
+		r.timer,
+		r.toggle,
+		r.reset,
+		r.notes,
+		r.moveUp,
+		r.moveDown,
+	)
+	r.ExtendBaseWidget(r)
+	return r
+}
+
+func (r *taskRow) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(r.box)
+}
+
+// bind shows item in the row and points its controls at it.
+func (r *taskRow) bind(a fyne.App, w fyne.Window, item *TodoItem) {
+	r.stripe.FillColor = listColor(item.ListID)
+	r.stripe.Refresh()
+
+	r.check.OnChanged = nil
+	r.check.SetChecked(item.Completed)
+	r.check.OnChanged = func(checked bool) {
+		item.Completed = checked
+		err := updateCompleted(item)
+		if err != nil {
+			item.Completed = !checked
+			showError(w, "update the task", err)
+		}
+		refreshTodoList(a, w)
+	}
+	state := taskStateOf(item)
+	style := taskStateStyles[state]
+
+	r.priority.SetText(priorityLabel(item.Priority))
+	r.title.Importance = widget.MediumImportance
+	r.strike.StrokeColor = color.Transparent
+	if item.Completed {
+		r.title.Importance = widget.LowImportance
+		r.strike.StrokeColor = theme.Color(theme.ColorNameDisabled)
+	}
+	r.title.SetText(item.Title)
+	r.tags.SetText(tagsLabel(item.Tags))
+	due := ""
+	if !item.DueDate.IsZero() {
+		due = item.DueDate.Format(dueDateLayout)
+	}
+	r.due.Importance = widget.MediumImportance
+	if isOverdue(item) {
+		r.due.Importance = widget.DangerImportance
+	}
+	r.due.SetText(due)
+	r.duration.SetText(item.Duration)
+	left, _ := trayIconProgress(item)
+	r.progress.set(1-left, style.color)
+	r.timer.Importance = style.importance
+	r.timer.SetText(formatTime(item.RemainingTime))
+
+	if item.Running {
+		r.toggle.SetText("Pause")
+		r.toggle.SetIcon(theme.MediaPauseIcon())
+	} else {
+		r.toggle.SetText("Start")
+		r.toggle.SetIcon(theme.MediaPlayIcon())
+	}
+	r.toggle.OnTapped = func() {
+		toggleTimer(w, item)
+	}
+	// Done tasks can still be paused, but not started.
+	setEnabled(r.toggle, item.Running || !item.Completed)
+	r.reset.OnTapped = func() {
+		err := resetTimer(item)
+		if err != nil {
+			showError(w, "reset the timer", err)
+		}
+	}
+	setEnabled(r.reset, item.Running || timerStarted(item))
+	r.notes.OnTapped = func() {
+		showNotes(a, w, item)
+	}
+	r.moveUp.OnTapped = func() {
+		moveAndRefresh(a, w, item, -1)
+	}
+	r.moveDown.OnTapped = func() {
+		moveAndRefresh(a, w, item, 1)
+	}
+	setEnabled(r.moveUp, currentList.SortMode == sortByManual)
+	setEnabled(r.moveDown, currentList.SortMode == sortByManual)
+
+	// Rows are reused, so the new texts need the row laid out again.
+	r.box.Refresh()
+}
+
+func setEnabled(button *widget.Button, enabled bool) {
+	if enabled {
+		button.Enable()
+	} else {
+		button.Disable()
+	}
+}
+
+// strikeLayout lays out a label with a line struck through its text. Fyne has
+// no strikethrough text style.
+type strikeLayout struct {
+	label *widget.Label
+}
+
+func (l *strikeLayout) Layout(objects []fyne.CanvasObject, size fyne.Size) {
+	objects[0].Resize(size)
+	objects[0].Move(fyne.NewPos(0, 0))
+	padding := theme.InnerPadding()
+	text := fyne.MeasureText(l.label.Text, theme.TextSize(), l.label.TextStyle)
+	width := min(text.Width, size.Width-2*padding)
+	line := objects[1].(*canvas.Line)
+	line.Position1 = fyne.NewPos(padding, size.Height/2)
+	line.Position2 = fyne.NewPos(padding+width, size.Height/2)
+}
+
+func (l *strikeLayout) MinSize(objects []fyne.CanvasObject) fyne.Size {
+	return objects[0].MinSize()
+}
+
+// taskProgress is a thin bar showing how much of a task's timer has elapsed,
+// in the color of the task's state.
+type taskProgress struct {
+	widget.BaseWidget
+	value float64
+	color fyne.ThemeColorName
+}
+
+func newTaskProgress() *taskProgress {
+	p := &taskProgress{color: theme.ColorNameDisabled}
+	p.ExtendBaseWidget(p)
+	return p
+}
+
+// set shows value, between 0 and 1, in the theme color named c.
+func (p *taskProgress) set(value float64, c fyne.ThemeColorName) {
+	p.value, p.color = value, c
+	p.Refresh()
+}
+
+func (p *taskProgress) CreateRenderer() fyne.WidgetRenderer {
+	r := &taskProgressRenderer{progress: p, track: canvas.NewRectangle(color.Transparent), bar: canvas.NewRectangle(color.Transparent)}
+	r.Refresh()
+	return r
+}
+
+const taskProgressHeight = 6
+
+type taskProgressRenderer struct {
+	progress   *taskProgress
+	track, bar *canvas.Rectangle
+}
+
+func (r *taskProgressRenderer) Layout(size fyne.Size) {
+	top := fyne.NewPos(0, (size.Height-taskProgressHeight)/2)
+	r.track.Move(top)
+	r.track.Resize(fyne.NewSize(size.Width, taskProgressHeight))
+	r.bar.Move(top)
+	r.bar.Resize(fyne.NewSize(size.Width*float32(r.progress.value), taskProgressHeight))
+}
+
+func (r *taskProgressRenderer) MinSize() fyne.Size {
+	return fyne.NewSize(80, taskProgressHeight)
+}
+
+func (r *taskProgressRenderer) Refresh() {
+	r.track.FillColor = theme.Color(theme.ColorNameInputBackground)
+	r.bar.FillColor = theme.Color(r.progress.color)
+	r.track.CornerRadius = taskProgressHeight / 2
+	r.bar.CornerRadius = taskProgressHeight / 2
+	r.Layout(r.progress.Size())
+	r.track.Refresh()
+	r.bar.Refresh()
+}
+
+func (r *taskProgressRenderer) Objects() []fyne.CanvasObject {
+	return []fyne.CanvasObject{r.track, r.bar}
+}
+
+func (r *taskProgressRenderer) Destroy() {}
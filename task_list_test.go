@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/test"
+	"testing"
+)
+
+// BenchmarkTaskList measures showing a list of 10,000 tasks, refreshing it
+// after an edit and refreshing the row of a running timer.
+func BenchmarkTaskList(b *testing.B) {
+	a := test.NewApp()
+	w := test.NewWindow(nil)
+	defer w.Close()
+	w.Resize(fyne.NewSize(800, 600))
+
+	previousList := currentList
+	currentList = &TodoList{ID: "benchmark", Name: "Benchmark", SortMode: sortByManual}
+	items := make([]*TodoItem, 10000)
+	for i := range items {
+		item, err := newTodoItem(fmt.Sprintf("Task %d", i+1), "25m", currentList.ID)
+		if err != nil {
+			b.Fatal(err)
+		}
+		item.Position = float64(i + 1)
+		item.Tags = []string{"work"}
+		items[i] = item
+	}
+	changeTodoItems(func([]*TodoItem) []*TodoItem {
+		return items
+	})
+	defer func() {
+		currentList = previousList
+		changeTodoItems(func([]*TodoItem) []*TodoItem {
+			return nil
+		})
+	}()
+
+	b.Run("show", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			w.SetContent(makeTodoListContainer(a, w))
+		}
+	})
+	w.SetContent(makeTodoListContainer(a, w))
+	b.Run("refresh", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			taskList.refresh()
+		}
+	})
+	b.Run("tick", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			refreshTimer(items[0])
+		}
+	})
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"image/color"
+)
+
+// GoDoTheme is the default theme with the appearance settings applied: a
+// fixed light or dark variant, an accent color in place of the primary color,
+// high-contrast colors and the text size.
+type GoDoTheme struct {
+	fyne.Theme
+	appearance appearanceConfig
+	// accent is nil if the theme's primary color is used.
+	accent color.Color
+}
+
+func newGoDoTheme(appearance appearanceConfig) fyne.Theme {
+	t := &GoDoTheme{Theme: theme.DefaultTheme(), appearance: appearance}
+	if accent, err := parseHexColor(appearance.Accent); err == nil {
+		t.accent = accent
+	}
+	return t
+}
+
+func (t *GoDoTheme) Color(name fyne.ThemeColorName, variant fyne.ThemeVariant) color.Color {
+	variant = t.variant(variant)
+	if t.accent != nil {
+		if c := accentColor(name, t.accent); c != nil {
+			return c
+		}
+	}
+	if t.appearance.HighContrast {
+		if c, ok := highContrastColors[variant][name]; ok {
+			return c
+		}
+	}
+	return t.Theme.Color(name, variant)
+}
+
+// variant returns the variant shown when the system asks for variant.
+func (t *GoDoTheme) variant(variant fyne.ThemeVariant) fyne.ThemeVariant {
+	switch t.appearance.Theme {
+	case themeDark:
+		return theme.VariantDark
+	case themeLight:
+		return theme.VariantLight
+	}
+	return variant
+}
+
+// themeVariant returns the variant the app is shown in.
+func themeVariant(a fyne.App) fyne.ThemeVariant {
+	variant := a.Settings().ThemeVariant()
+	if t, ok := a.Settings().Theme().(*GoDoTheme); ok {
+		return t.variant(variant)
+	}
+	return variant
+}
+
+func (t *GoDoTheme) Size(name fyne.ThemeSizeName) float32 {
+	if name == theme.SizeNameText && t.appearance.TextSize > 0 {
+		return t.appearance.TextSize
+	}
+	return t.Theme.Size(name)
+}
+
+// accentColor returns the color named name derived from accent, or nil if
+// the accent does not change it.
+func accentColor(name fyne.ThemeColorName, accent color.Color) color.Color {
+	c := color.NRGBAModel.Convert(accent).(color.NRGBA)
+	switch name {
+	case theme.ColorNamePrimary, theme.ColorNameHyperlink:
+		return c
+	case theme.ColorNameFocus:
+		c.A = 0x7f
+		return c
+	case theme.ColorNameSelection:
+		c.A = 0x40
+		return c
+	case theme.ColorNameForegroundOnPrimary:
+		// Relative luminance as in WCAG, simplified to linear channels.
+		if 0.2126*float64(c.R)+0.7152*float64(c.G)+0.0722*float64(c.B) > 150 {
+			return color.Black
+		}
+		return color.White
+	}
+	return nil
+}
+
+var highContrastColors = map[fyne.ThemeVariant]map[fyne.ThemeColorName]color.Color{
+	theme.VariantDark: {
+		theme.ColorNameBackground:          color.Black,
+		theme.ColorNameForeground:          color.White,
+		theme.ColorNameButton:              color.NRGBA{R: 0x26, G: 0x26, B: 0x26, A: 0xff},
+		theme.ColorNameDisabledButton:      color.NRGBA{R: 0x12, G: 0x12, B: 0x12, A: 0xff},
+		theme.ColorNameDisabled:            color.NRGBA{R: 0xa6, G: 0xa6, B: 0xa6, A: 0xff},
+		theme.ColorNamePlaceHolder:         color.NRGBA{R: 0xc8, G: 0xc8, B: 0xc8, A: 0xff},
+		theme.ColorNameInputBackground:     color.Black,
+		theme.ColorNameInputBorder:         color.White,
+		theme.ColorNameSeparator:           color.White,
+		theme.ColorNameHover:               color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0x40},
+		theme.ColorNamePressed:             color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0x66},
+		theme.ColorNameScrollBar:           color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xcc},
+		theme.ColorNameHeaderBackground:    color.Black,
+		theme.ColorNameMenuBackground:      color.Black,
+		theme.ColorNameOverlayBackground:   color.Black,
+		theme.ColorNamePrimary:             color.NRGBA{R: 0xff, G: 0xd6, B: 0x00, A: 0xff},
+		theme.ColorNameHyperlink:           color.NRGBA{R: 0xff, G: 0xd6, B: 0x00, A: 0xff},
+		theme.ColorNameFocus:               color.NRGBA{R: 0xff, G: 0xd6, B: 0x00, A: 0x99},
+		theme.ColorNameSelection:           color.NRGBA{R: 0xff, G: 0xd6, B: 0x00, A: 0x66},
+		theme.ColorNameForegroundOnPrimary: color.Black,
+	},
+	theme.VariantLight: {
+		theme.ColorNameBackground:          color.White,
+		theme.ColorNameForeground:          color.Black,
+		theme.ColorNameButton:              color.NRGBA{R: 0xe0, G: 0xe0, B: 0xe0, A: 0xff},
+		theme.ColorNameDisabledButton:      color.NRGBA{R: 0xf2, G: 0xf2, B: 0xf2, A: 0xff},
+		theme.ColorNameDisabled:            color.NRGBA{R: 0x59, G: 0x59, B: 0x59, A: 0xff},
+		theme.ColorNamePlaceHolder:         color.NRGBA{R: 0x3d, G: 0x3d, B: 0x3d, A: 0xff},
+		theme.ColorNameInputBackground:     color.White,
+		theme.ColorNameInputBorder:         color.Black,
+		theme.ColorNameSeparator:           color.Black,
+		theme.ColorNameHover:               color.NRGBA{A: 0x26},
+		theme.ColorNamePressed:             color.NRGBA{A: 0x40},
+		theme.ColorNameScrollBar:           color.NRGBA{A: 0xcc},
+		theme.ColorNameHeaderBackground:    color.White,
+		theme.ColorNameMenuBackground:      color.White,
+		theme.ColorNameOverlayBackground:   color.White,
+		theme.ColorNamePrimary:             color.NRGBA{B: 0xc8, A: 0xff},
+		theme.ColorNameHyperlink:           color.NRGBA{B: 0xc8, A: 0xff},
+		theme.ColorNameFocus:               color.NRGBA{B: 0xc8, A: 0x99},
+		theme.ColorNameSelection:           color.NRGBA{B: 0xc8, A: 0x40},
+		theme.ColorNameForegroundOnPrimary: color.White,
+	},
+}
+
+// parseHexColor parses a "#rrggbb" color.
+func parseHexColor(s string) (color.NRGBA, error) {
+	c := color.NRGBA{A: 0xff}
+	_, err := fmt.Sscanf(s, "#%02x%02x%02x", &c.R, &c.G, &c.B)
+	if err != nil || len(s) != 7 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q, want #rrggbb", s)
+	}
+	return c, nil
+}
+
+func formatHexColor(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B)
+}
+
+// listColor returns the color of the list with the given ID, transparent if
+// it has none.
+func listColor(listID string) color.Color {
+	c, err := parseHexColor(findTodoList(listID).Color)
+	if err != nil {
+		return color.Transparent
+	}
+	return c
+}
+
+// newListStripe returns the bar marking a task row with the color of its list.
+func newListStripe(c color.Color) *canvas.Rectangle {
+	stripe := canvas.NewRectangle(c)
+	stripe.SetMinSize(fyne.NewSize(4, 0))
+	return stripe
+}
+
+// showColorDialog lets the user pick one of the theme's primary colors or any
+// other color. Picking noneLabel chooses "", no color.
+func showColorDialog(title, noneLabel string, chosen func(hex string), w fyne.Window) {
+	var d dialog.Dialog
+	swatches := container.NewGridWithColumns(4)
+	for _, name := range theme.PrimaryColorNames() {
+		swatches.Add(newColorSwatch(theme.PrimaryColorNamed(name), func(c color.Color) {
+			d.Hide()
+			chosen(formatHexColor(c))
+		}))
+	}
+	noneButton := widget.NewButton(noneLabel, func() {
+		d.Hide()
+		chosen("")
+	})
+	customButton := widget.NewButton("Custom…", func() {
+		d.Hide()
+		picker := dialog.NewColorPicker(title, "", func(c color.Color) {
+			chosen(formatHexColor(c))
+		}, w)
+		picker.Advanced = true
+		picker.Show()
+	})
+	d = dialog.NewCustom(title, "Cancel", container.NewVBox(swatches, container.NewGridWithColumns(2, noneButton, customButton)), w)
+	d.Show()
+}
+
+// colorSwatch is a tappable patch of color.
+type colorSwatch struct {
+	widget.BaseWidget
+	color    color.Color
+	onTapped func(c color.Color)
+}
+
+func newColorSwatch(c color.Color, onTapped func(c color.Color)) *colorSwatch {
+	s := &colorSwatch{color: c, onTapped: onTapped}
+	s.ExtendBaseWidget(s)
+	return s
+}
+
+func (s *colorSwatch) CreateRenderer() fyne.WidgetRenderer {
+	patch := canvas.NewRectangle(s.color)
+	patch.CornerRadius = theme.InputRadiusSize()
+	patch.SetMinSize(fyne.NewSize(48, 32))
+	return widget.NewSimpleRenderer(patch)
+}
+
+func (s *colorSwatch) Tapped(*fyne.PointEvent) {
+	s.onTapped(s.color)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+// todoItemJSON is the JSON form of a TodoItem. It carries every stored field
+// so that JSON output can be read back without losing anything.
+type todoItemJSON struct {
+	ID            string     `json:"id"`
+	Title         string     `json:"title"`
+	Duration      string     `json:"duration"`
+	RemainingTime string     `json:"remaining_time"`
+	Completed     bool       `json:"completed"`
+	Running       bool       `json:"running"`
+	Priority      int        `json:"priority"`
+	DueDate       *time.Time `json:"due_date,omitempty"`
+	CreatedAt     *time.Time `json:"created_at,omitempty"`
+	ArchivedAt    *time.Time `json:"archived_at,omitempty"`
+	ListID        string     `json:"list_id"`
+	Position      float64    `json:"position"`
+	Notes         string     `json:"notes,omitempty"`
+	Tags          []string   `json:"tags,omitempty"`
+	Recurrence    string     `json:"recurrence,omitempty"`
+}
+
+func (item *TodoItem) MarshalJSON() ([]byte, error) {
+	return json.Marshal(todoItemJSON{
+		ID:            item.ID.String(),
+		Title:         item.Title,
+		Duration:      item.Duration,
+		RemainingTime: item.RemainingTime.String(),
+		Completed:     item.Completed,
+		Running:       item.Running,
+		Priority:      item.Priority,
+		DueDate:       optionalTime(item.DueDate),
+		CreatedAt:     optionalTime(item.CreatedAt),
+		ArchivedAt:    optionalTime(item.ArchivedAt),
+		ListID:        item.ListID,
+		Position:      item.Position,
+		Notes:         item.Notes,
+		Tags:          item.Tags,
+		Recurrence:    item.Recurrence,
+	})
+}
+
+func (item *TodoItem) UnmarshalJSON(data []byte) error {
+	var v todoItemJSON
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return err
+	}
+
+	id, err := uuid.Parse(v.ID)
+	if err != nil {
+		return err
+	}
+	// The git history leaves the timer out; such tasks start at their full
+	// duration.
+	if v.RemainingTime == "" {
+		v.RemainingTime = v.Duration
+	}
+	remainingTime, err := time.ParseDuration(v.RemainingTime)
+	if err != nil {
+		return err
+	}
+
+	*item = TodoItem{
+		ID:            id,
+		Title:         v.Title,
+		Duration:      v.Duration,
+		RemainingTime: remainingTime,
+		Completed:     v.Completed,
+		Running:       v.Running,
+		Priority:      v.Priority,
+		ListID:        v.ListID,
+		Position:      v.Position,
+		Notes:         v.Notes,
+		Tags:          v.Tags,
+		Recurrence:    v.Recurrence,
+	}
+	if v.DueDate != nil {
+		item.DueDate = *v.DueDate
+	}
+	if v.CreatedAt != nil {
+		item.CreatedAt = *v.CreatedAt
+	}
+	if v.ArchivedAt != nil {
+		item.ArchivedAt = *v.ArchivedAt
+	}
+	return nil
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/widget"
+	"strings"
+	"sync"
+	"time"
+)
+
+const recentTasksInTray = 8
+
+// trayMenu is the system tray menu. It follows the task whose timer is
+// running, or was paused last, and offers controls for it. Pause keeps the
+// task in the menu to start it again, Stop pauses it and lets the menu go.
+type trayMenu struct {
+	a    fyne.App
+	w    fyne.Window
+	desk desktop.App
+	menu *fyne.Menu
+	mini *miniTimer
+
+	// lock guards the fields below, which the menu's actions and the ticking
+	// goroutine both use.
+	lock sync.Mutex
+
+	status, start, pause, stop, reset, recent *fyne.MenuItem
+
+	// item is the task the controls act on, nil if there is none.
+	item *TodoItem
+	// shown, shownItem and shownRunning are what the menu shows, to refresh
+	// it only after changes.
+	shown        string
+	shownItem    *TodoItem
+	shownRunning bool
+	// icon identifies the tray icon shown, to redraw it only after changes.
+	icon string
+	// acknowledged is set once the user has seen that the timer finished,
+	// which stops the icon flashing.
+	acknowledged bool
+	flashOn      bool
+}
+
+func makeTrayMenu(a fyne.App, w fyne.Window, desk desktop.App) {
+	t := &trayMenu{a: a, w: w, desk: desk}
+	t.status = fyne.NewMenuItem("", nil)
+	t.status.Disabled = true
+	t.start = fyne.NewMenuItem("Start", func() { t.control(startTimer) })
+	t.pause = fyne.NewMenuItem("Pause", func() { t.control(stopTimer) })
+	t.stop = fyne.NewMenuItem("Stop", t.stopFollowing)
+	t.reset = fyne.NewMenuItem("Reset", func() { t.control(resetTimer) })
+	t.recent = fyne.NewMenuItem("Recent tasks", nil)
+	t.mini = newMiniTimer(a, t)
+	quit := fyne.NewMenuItem("Quit", func() { quitGoDo(a) })
+	quit.IsQuit = true
+
+	t.menu = fyne.NewMenu("GoDo",
+		t.status,
+		t.start,
+		t.pause,
+		t.stop,
+		t.reset,
+		fyne.NewMenuItemSeparator(),
+		t.recent,
+		fyne.NewMenuItem("Quick add…", func() { showQuickAddWindow(a, w) }),
+		fyne.NewMenuItem("Mini timer", func() { t.mini.toggle() }),
+		fyne.NewMenuItem("Show", func() {
+			t.acknowledge()
+			w.Show()
+		}),
+		fyne.NewMenuItemSeparator(),
+		quit,
+	)
+	desk.SetSystemTrayMenu(t.menu)
+	t.update()
+
+	go func() {
+		for range time.Tick(time.Second) {
+			t.update()
+		}
+	}()
+}
+
+// update follows the running task and refreshes the menu if its remaining
+// time or state changed.
+func (t *trayMenu) update() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	// The timers change the tasks on their own goroutines, so the menu shows
+	// copies taken under timerLock.
+	items := currentTodoItems()
+	for _, item := range items {
+		if item.snapshot().Running {
+			t.item = item
+			break
+		}
+	}
+	if t.item != nil && indexOfTodoItem(items, t.item) < 0 {
+		t.item = nil
+	}
+
+	var item *TodoItem
+	if t.item != nil {
+		item = t.item.snapshot()
+	}
+	running := item != nil && item.Running
+	t.updateIcon(item)
+	t.mini.update(item)
+	shown := "No task running"
+	if item != nil {
+		state := "Paused"
+		if running {
+			state = "Running"
+		} else if item.RemainingTime <= 0 {
+			state = "Finished"
+		}
+		shown = fmt.Sprintf("%s: %s  %s", state, item.Title, formatTime(item.RemainingTime))
+	}
+	if shown == t.shown {
+		return
+	}
+	// Starting and stopping timers is what changes the recent tasks.
+	if t.shown == "" || t.item != t.shownItem || running != t.shownRunning {
+		t.recent.ChildMenu = t.recentMenu()
+	}
+	t.shown, t.shownItem, t.shownRunning = shown, t.item, running
+
+	t.status.Label = shown
+	t.start.Disabled = item == nil || item.Running
+	t.pause.Disabled = item == nil || !item.Running
+	t.stop.Disabled = item == nil
+	t.reset.Disabled = item == nil
+	t.menu.Refresh()
+}
+
+// updateIcon shows the state of item in the tray icon, flashing it while a
+// finished timer is not acknowledged.
+func (t *trayMenu) updateIcon(item *TodoItem) {
+	state := trayIconStateOf(item)
+	if state != trayFinished {
+		t.acknowledged = false
+	}
+
+	icon := "logo"
+	var resource fyne.Resource = resourceLogoWindowmanagerWhitePng
+	switch {
+	case state == trayFinished && !t.acknowledged:
+		t.flashOn = !t.flashOn
+		if t.flashOn {
+			icon = "finished"
+			resource = renderTrayIcon(state, 1, 0)
+		}
+	case state != trayIdle && state != trayFinished:
+		progress, minutes := trayIconProgress(item)
+		icon = fmt.Sprintf("%d/%d/%d", state, int(progress*100), minutes)
+		if icon != t.icon {
+			resource = renderTrayIcon(state, progress, minutes)
+		}
+	}
+	if icon != t.icon {
+		t.icon = icon
+		t.desk.SetSystemTrayIcon(resource)
+	}
+}
+
+// acknowledge stops the icon flashing for a finished timer.
+func (t *trayMenu) acknowledge() {
+	t.lock.Lock()
+	t.acknowledged = true
+	t.lock.Unlock()
+	t.update()
+}
+
+// toggleTimer pauses the timer of the task the tray follows if it runs and
+// starts it otherwise.
+func (t *trayMenu) toggleTimer() {
+	t.lock.Lock()
+	running := t.item != nil && t.item.snapshot().Running
+	t.lock.Unlock()
+	if running {
+		t.control(stopTimer)
+	} else {
+		t.control(startTimer)
+	}
+}
+
+// stopFollowing stops the timer of the task the tray follows and lets the
+// task go.
+func (t *trayMenu) stopFollowing() {
+	t.control(stopTimer)
+	t.lock.Lock()
+	t.item = nil
+	t.lock.Unlock()
+	t.update()
+}
+
+func (t *trayMenu) control(action func(*TodoItem) error) {
+	t.lock.Lock()
+	item := t.item
+	t.lock.Unlock()
+	if item == nil {
+		return
+	}
+	logTimerError(action(item))
+	t.update()
+}
+
+// recentMenu lists the tasks whose timers ran last, to start them again.
+func (t *trayMenu) recentMenu() *fyne.Menu {
+	items, err := getRecentTodoItems(recentTasksInTray)
+	if err != nil {
+		logTimerError(err)
+	}
+	if len(items) == 0 {
+		none := fyne.NewMenuItem("No recent tasks", nil)
+		none.Disabled = true
+		return fyne.NewMenu("", none)
+	}
+
+	menuItems := make([]*fyne.MenuItem, len(items))
+	for i, item := range items {
+		menuItems[i] = fyne.NewMenuItem(item.Title, func(item *TodoItem) func() {
+			return func() {
+				t.startRecent(item)
+			}
+		}(item))
+	}
+	return fyne.NewMenu("", menuItems...)
+}
+
+// startRecent starts the timer of a recent task, switching to its list
+// first so that the GUI shows the running timer.
+func (t *trayMenu) startRecent(recent *TodoItem) {
+	if currentList.ID != recent.ListID {
+		for _, list := range todoLists {
+			if list.ID == recent.ListID {
+				err := switchList(list)
+				if err != nil {
+					logTimerError(err)
+					return
+				}
+				refreshLists(t.a, t.w)
+				break
+			}
+		}
+	}
+	for _, item := range currentTodoItems() {
+		if item.ID == recent.ID {
+			t.lock.Lock()
+			t.item = item
+			t.lock.Unlock()
+			t.control(startTimer)
+			return
+		}
+	}
+}
+
+// showQuickAddWindow asks for a task only, with an optional duration after
+// the title, and adds it to the current list.
+func showQuickAddWindow(a fyne.App, w fyne.Window) {
+	inputWindow := a.NewWindow("Quick add")
+	inputWindow.Resize(fyne.NewSize(300, 0))
+
+	taskEntry := widget.NewEntry()
+	taskEntry.SetPlaceHolder("Task, optionally followed by a duration like 25m")
+	taskEntry.OnSubmitted = func(text string) {
+		title, duration := parseQuickAdd(text)
+		if title == "" {
+			return
+		}
+		newItem, err := newTodoItem(title, duration, currentList.ID)
+		if err != nil {
+			showInvalidInput(inputWindow, "Please enter a duration like 25m.")
+			return
+		}
+		newItem, err = addTodoItem(newItem)
+		if err != nil {
+			showError(inputWindow, "add the task", err)
+			return
+		}
+		if daemon != nil {
+			err = switchList(currentList)
+			if err != nil {
+				showError(w, "reload the tasks", err)
+			}
+		} else {
+			changeTodoItems(func(items []*TodoItem) []*TodoItem {
+				return append(items, newItem)
+			})
+		}
+		refreshTodoList(a, w)
+		inputWindow.Close()
+	}
+
+	inputWindow.SetContent(container.NewVBox(taskEntry, widget.NewButton("Add", func() {
+		taskEntry.OnSubmitted(taskEntry.Text)
+	})))
+	inputWindow.Canvas().Focus(taskEntry)
+	inputWindow.Show()
+}
+
+// parseQuickAdd splits "Write report 45m" into its title and duration.
+func parseQuickAdd(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	if i := strings.LastIndex(text, " "); i > 0 {
+		if _, err := time.ParseDuration(text[i+1:]); err == nil {
+			return strings.TrimSpace(text[:i]), text[i+1:]
+		}
+	}
+	return text, newTaskDuration()
+}
+
+// quitGoDo stops the timers running in this process, which stores their
+// remaining time, and quits. Timers running in the daemon keep running.
+func quitGoDo(a fyne.App) {
+	if daemon == nil {
+		for _, item := range currentTodoItems() {
+			if item.Running {
+				logTimerError(item.StopTimer())
+			}
+		}
+	}
+	a.Quit()
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The tray icon is drawn at runtime: a ring that empties as the active timer
+// runs down, with the minutes left inside it. Paused timers show a grey ring
+// with a pause sign, breaks a green ring and a finished timer flashes red
+// until it is acknowledged.
+
+type trayIconState int
+
+const (
+	trayIdle trayIconState = iota
+	trayRunning
+	trayPaused
+	trayBreak
+	trayFinished
+)
+
+// breakTag marks tasks that are breaks rather than work.
+const breakTag = "break"
+
+const trayIconSize = 64
+
+var (
+	trayRunningColor  = color.NRGBA{R: 0x42, G: 0x9b, B: 0xf5, A: 0xff}
+	trayPausedColor   = color.NRGBA{R: 0x9e, G: 0x9e, B: 0x9e, A: 0xff}
+	trayBreakColor    = color.NRGBA{R: 0x4c, G: 0xaf, B: 0x50, A: 0xff}
+	trayFinishedColor = color.NRGBA{R: 0xf4, G: 0x43, B: 0x36, A: 0xff}
+	trayTrackColor    = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0x40}
+	trayTextColor     = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+)
+
+// trayIconDigits is a 3x5 pixel font for the minutes, one string per row.
+var trayIconDigits = [10][5]string{
+	{"###", "#.#", "#.#", "#.#", "###"},
+	{".#.", "##.", ".#.", ".#.", "###"},
+	{"###", "..#", "###", "#..", "###"},
+	{"###", "..#", "###", "..#", "###"},
+	{"#.#", "#.#", "###", "..#", "..#"},
+	{"###", "#..", "###", "..#", "###"},
+	{"###", "#..", "###", "#.#", "###"},
+	{"###", "..#", ".#.", ".#.", ".#."},
+	{"###", "#.#", "###", "#.#", "###"},
+	{"###", "#.#", "###", "..#", "###"},
+}
+
+// trayIconStateOf returns the state the tray icon shows for item, the task the
+// tray menu follows, which may be nil.
+func trayIconStateOf(item *TodoItem) trayIconState {
+	switch {
+	case item == nil:
+		return trayIdle
+	case !item.Running && item.RemainingTime <= 0:
+		return trayFinished
+	case !item.Running:
+		return trayPaused
+	case hasTag(item, breakTag):
+		return trayBreak
+	}
+	return trayRunning
+}
+
+func hasTag(item *TodoItem, tag string) bool {
+	for _, t := range item.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// trayIconProgress returns the part of item's duration that is left and the
+// whole minutes left, rounded up.
+func trayIconProgress(item *TodoItem) (float64, int) {
+	minutes := int(math.Ceil(item.RemainingTime.Minutes()))
+	duration, err := time.ParseDuration(item.Duration)
+	if err != nil || duration <= 0 {
+		return 1, minutes
+	}
+	return math.Min(1, math.Max(0, float64(item.RemainingTime)/float64(duration))), minutes
+}
+
+// renderTrayIcon draws the icon for a timer in state. progress and minutes
+// are ignored for finished timers.
+func renderTrayIcon(state trayIconState, progress float64, minutes int) fyne.Resource {
+	img := image.NewNRGBA(image.Rect(0, 0, trayIconSize, trayIconSize))
+	ringColor := trayRunningColor
+	switch state {
+	case trayPaused:
+		ringColor = trayPausedColor
+	case trayBreak:
+		ringColor = trayBreakColor
+	case trayFinished:
+		ringColor = trayFinishedColor
+		progress = 1
+	}
+
+	center := float64(trayIconSize) / 2
+	outer, inner := center-1, center-9
+	if state == trayFinished {
+		inner = 0
+	}
+	// Each pixel is sampled 4x4 times for smooth edges.
+	const samples = 4
+	for y := 0; y < trayIconSize; y++ {
+		for x := 0; x < trayIconSize; x++ {
+			var filled, track int
+			for sy := 0; sy < samples; sy++ {
+				for sx := 0; sx < samples; sx++ {
+					dx := float64(x) + (float64(sx)+0.5)/samples - center
+					dy := float64(y) + (float64(sy)+0.5)/samples - center
+					r := math.Hypot(dx, dy)
+					if r > outer || r < inner {
+						continue
+					}
+					// Clockwise from twelve o'clock.
+					angle := math.Atan2(dx, -dy)
+					if angle < 0 {
+						angle += 2 * math.Pi
+					}
+					if angle/(2*math.Pi) < progress {
+						filled++
+					} else {
+						track++
+					}
+				}
+			}
+			c := blendCoverage(ringColor, filled, samples*samples)
+			if track > 0 {
+				c = over(c, blendCoverage(trayTrackColor, track, samples*samples))
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	switch state {
+	case trayPaused:
+		fillRect(img, image.Rect(22, 20, 29, 44), trayTextColor)
+		fillRect(img, image.Rect(35, 20, 42, 44), trayTextColor)
+	case trayRunning, trayBreak:
+		drawTrayIconNumber(img, minutes)
+	}
+
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, img)
+	// Icons are told apart by name, so every image gets its own.
+	name := fmt.Sprintf("tray-%d-%.0f-%d.png", state, progress*100, minutes)
+	return fyne.NewStaticResource(name, buf.Bytes())
+}
+
+// drawTrayIconNumber draws n centred, as large as fits inside the ring.
+func drawTrayIconNumber(img *image.NRGBA, n int) {
+	if n > 999 {
+		n = 999
+	}
+	text := strconv.Itoa(n)
+	scale := 6
+	if len(text) == 2 {
+		scale = 4
+	} else if len(text) == 3 {
+		scale = 3
+	}
+	width := (len(text)*4 - 1) * scale
+	left, top := (trayIconSize-width)/2, (trayIconSize-5*scale)/2
+	for i, digit := range text {
+		glyph := trayIconDigits[digit-'0']
+		for row, line := range glyph {
+			for col, pixel := range line {
+				if pixel != '#' {
+					continue
+				}
+				x := left + (i*4+col)*scale
+				y := top + row*scale
+				fillRect(img, image.Rect(x, y, x+scale, y+scale), trayTextColor)
+			}
+		}
+	}
+}
+
+func fillRect(img *image.NRGBA, r image.Rectangle, c color.NRGBA) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+}
+
+// blendCoverage returns c with its alpha scaled by covered/total.
+func blendCoverage(c color.NRGBA, covered, total int) color.NRGBA {
+	c.A = uint8(int(c.A) * covered / total)
+	return c
+}
+
+// over composites top over bottom.
+func over(bottom, top color.NRGBA) color.NRGBA {
+	ta, ba := float64(top.A)/255, float64(bottom.A)/255
+	a := ta + ba*(1-ta)
+	if a == 0 {
+		return color.NRGBA{}
+	}
+	mix := func(t, b uint8) uint8 {
+		return uint8((float64(t)*ta + float64(b)*ba*(1-ta)) / a)
+	}
+	return color.NRGBA{R: mix(top.R, bottom.R), G: mix(top.G, bottom.G), B: mix(top.B, bottom.B), A: uint8(a * 255)}
+}
@@ -0,0 +1,876 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/google/uuid"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The vault mirrors every list into a Markdown file in a folder, one
+// checklist line per task with its metadata inline:
+//
+//	---
+//	godo-list: 6f1c2a9e-58b4-4d1a-9c39-2b7f0e0d6a11
+//	---
+//
+//	# Work
+//
+//	- [ ] Write report ⏱ 25m 📅 2026-10-20 ⏫ #work 🆔 0db5aa0e-ed98-4ddd-a661-af9e194cd4af
+//	    Notes are indented below the task.
+//
+// The priority markers are those of the Obsidian Tasks plugin: ⏫ P1, 🔼 P2
+// and 🔽 P3. The godo-list front matter names the list of a file; files
+// without it are other notes and left alone, and adding it with an empty
+// value makes a file a new list. Every write to the database rewrites the
+// task lines of the files, keeping the other lines in place, and edits to
+// the files are read back into the database while a watcher runs. Lines
+// without an ID are new tasks, and tasks whose line was removed are
+// archived. Title words that would be read as a tag or marker, such as
+// #1, are written with a leading backslash.
+
+const vaultListKey = "godo-list"
+
+const (
+	vaultDurationMarker = "⏱"
+	vaultDueMarker      = "📅"
+	vaultIDMarker       = "🆔"
+)
+
+var vaultPriorityMarkers = map[int]string{1: "⏫", 2: "🔼", 3: "🔽"}
+
+// vaultDebounce is how long the watcher waits for an editor to finish saving
+// a file before reading it.
+const vaultDebounce = 200 * time.Millisecond
+
+var vaultStore *vault
+
+type vault struct {
+	dir string
+
+	// syncLock serialises syncs within this process.
+	syncLock sync.Mutex
+
+	// lock guards the fields below.
+	lock    sync.Mutex
+	syncing bool
+	pending bool
+	timer   *time.Timer
+	watcher *fsnotify.Watcher
+	closed  bool
+
+	// changed wakes the goroutine writing database changes until done is
+	// closed, after which it closes stopped.
+	changed chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// openVault syncs dir with the database and from then on rewrites the files
+// whenever the database changes.
+func openVault(dir string) (*vault, error) {
+	dir, err := expandHome(dir)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &vault{
+		dir:     dir,
+		changed: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	_, err = v.sync()
+	if err != nil {
+		return nil, err
+	}
+
+	go v.writeChanges()
+	previous := todosChanged
+	todosChanged = func() {
+		previous()
+		v.databaseChanged()
+	}
+	return v, nil
+}
+
+// close stops the watcher and mirroring the database, once a sync in progress
+// is done and the changes waiting to be written are.
+func (v *vault) close() error {
+	v.lock.Lock()
+	if v.closed {
+		v.lock.Unlock()
+		return nil
+	}
+	v.closed = true
+	if v.timer != nil {
+		v.timer.Stop()
+		v.timer = nil
+	}
+	watcher := v.watcher
+	v.lock.Unlock()
+
+	close(v.done)
+	<-v.stopped
+	v.syncLock.Lock()
+	defer v.syncLock.Unlock()
+	if watcher == nil {
+		return nil
+	}
+	return watcher.Close()
+}
+
+func (v *vault) isClosed() bool {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	return v.closed
+}
+
+// databaseChanged has the files written in the background. Changes made
+// within vaultDebounce of each other are written together.
+func (v *vault) databaseChanged() {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if v.closed {
+		return
+	}
+	if v.syncing {
+		// sync writes the files again once it is done.
+		v.pending = true
+		return
+	}
+	select {
+	case v.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (v *vault) writeChanges() {
+	defer close(v.stopped)
+	for {
+		select {
+		case <-v.changed:
+		case <-v.done:
+			// A change made just before close is written all the same.
+			select {
+			case <-v.changed:
+				v.syncChanges()
+			default:
+			}
+			return
+		}
+
+		select {
+		case <-time.After(vaultDebounce):
+		case <-v.done:
+		}
+		// Changes made while waiting are written by this sync too.
+		select {
+		case <-v.changed:
+		default:
+		}
+		v.syncChanges()
+	}
+}
+
+// syncChanges is sync for writeChanges, which also syncs while the vault is
+// closing: close waits for it to be done.
+func (v *vault) syncChanges() {
+	v.syncLock.Lock()
+	defer v.syncLock.Unlock()
+	_, err := v.syncLocked()
+	if err != nil {
+		slog.Error("vault", "error", err)
+	}
+}
+
+// watch syncs the vault whenever another program changes a file in it and
+// calls changed with the tasks that were added, edited or archived.
+func (v *vault) watch(changed func(items []*TodoItem)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	err = watcher.Add(v.dir)
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+	v.lock.Lock()
+	v.watcher = watcher
+	v.lock.Unlock()
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Ext(event.Name) == ".md" && event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+					v.schedule(changed)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Error("vault", "error", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// schedule syncs once no file has changed for vaultDebounce.
+func (v *vault) schedule(changed func(items []*TodoItem)) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if v.closed {
+		return
+	}
+	if v.timer != nil {
+		v.timer.Reset(vaultDebounce)
+		return
+	}
+	v.timer = time.AfterFunc(vaultDebounce, func() {
+		v.lock.Lock()
+		v.timer = nil
+		closed := v.closed
+		v.lock.Unlock()
+		if closed {
+			return
+		}
+
+		items, err := v.sync()
+		if err != nil {
+			slog.Error("vault", "error", err)
+		}
+		if len(items) > 0 {
+			changed(items)
+		}
+	})
+}
+
+// sync reads the files edited since they were last synced into the database,
+// then writes every list whose file differs from the database. It returns
+// the tasks the files changed.
+func (v *vault) sync() ([]*TodoItem, error) {
+	v.syncLock.Lock()
+	defer v.syncLock.Unlock()
+	if v.isClosed() {
+		return nil, nil
+	}
+	return v.syncLocked()
+}
+
+// syncLocked is sync for callers holding syncLock.
+func (v *vault) syncLocked() ([]*TodoItem, error) {
+	v.setSyncing(true)
+	defer v.setSyncing(false)
+
+	var changed []*TodoItem
+	for {
+		paths, err := filepath.Glob(filepath.Join(v.dir, "*.md"))
+		if err != nil {
+			return changed, err
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return changed, err
+			}
+			stored, err := getVaultHash(path)
+			if err != nil {
+				return changed, err
+			}
+			listID, marked := vaultListID(string(data))
+			if !marked && stored == "" {
+				// Not a GoDo file. Files GoDo wrote before it added front
+				// matter have a hash.
+				continue
+			}
+			if vaultHash(data) == stored {
+				continue
+			}
+			list, err := v.listForFile(path, listID)
+			if err != nil {
+				return changed, fmt.Errorf("%s: %w", path, err)
+			}
+			items, err := v.applyTasks(list, parseVaultFile(string(data)))
+			changed = append(changed, items...)
+			if err != nil {
+				return changed, fmt.Errorf("%s: %w", path, err)
+			}
+			if list.ID != listID {
+				data = []byte(joinVaultLines(setVaultListID(vaultLines(string(data)), list.ID)))
+				err = os.WriteFile(path, data, 0644)
+				if err != nil {
+					return changed, err
+				}
+			}
+			err = saveVaultHash(path, data)
+			if err != nil {
+				return changed, err
+			}
+		}
+
+		err = v.writeAll()
+		if err != nil {
+			return changed, err
+		}
+
+		v.lock.Lock()
+		pending := v.pending
+		v.pending = false
+		v.lock.Unlock()
+		if !pending {
+			return changed, nil
+		}
+	}
+}
+
+func (v *vault) setSyncing(syncing bool) {
+	v.lock.Lock()
+	v.syncing = syncing
+	v.lock.Unlock()
+}
+
+func vaultHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func getVaultHash(path string) (string, error) {
+	var hash string
+	err := db.QueryRow(`SELECT hash FROM vault_files WHERE path = ?`, path).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return hash, err
+}
+
+func saveVaultHash(path string, data []byte) error {
+	_, err := execWrite(`INSERT INTO vault_files (path, hash) VALUES (?, ?)
+		ON CONFLICT (path) DO UPDATE SET hash = excluded.hash`, path, vaultHash(data))
+	return err
+}
+
+func vaultFileName(list *TodoList) string {
+	return strings.NewReplacer("/", "-", "\\", "-").Replace(list.Name) + ".md"
+}
+
+// writeAll writes every list whose file differs from the database.
+func (v *vault) writeAll() error {
+	lists, err := getTodoLists()
+	if err != nil {
+		return err
+	}
+	files, err := v.listFiles(lists)
+	if err != nil {
+		return err
+	}
+	for _, list := range lists {
+		items, err := getTodoItems(list.ID)
+		if err != nil {
+			return err
+		}
+		var current []byte
+		path, ok := files[list.ID]
+		if ok {
+			current, err = os.ReadFile(path)
+		} else {
+			path, err = v.newFilePath(list)
+		}
+		if err != nil {
+			return err
+		}
+
+		content := []byte(renderVaultFile(list, items, string(current)))
+		if !ok || !bytes.Equal(current, content) {
+			err = os.WriteFile(path, content, 0644)
+			if err != nil {
+				return err
+			}
+		}
+		err = saveVaultHash(path, content)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listFiles returns the path of the file of each list that has one.
+func (v *vault) listFiles(lists []*TodoList) (map[string]string, error) {
+	paths, err := filepath.Glob(filepath.Join(v.dir, "*.md"))
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]string{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		listID, marked := vaultListID(string(data))
+		if !marked {
+			stored, err := getVaultHash(path)
+			if err != nil {
+				return nil, err
+			}
+			list := listByFileName(lists, path)
+			if stored == "" || list == nil {
+				continue
+			}
+			listID = list.ID
+		}
+		files[listID] = path
+	}
+	return files, nil
+}
+
+// newFilePath returns a path for the file of list that is not taken by
+// another file.
+func (v *vault) newFilePath(list *TodoList) (string, error) {
+	name := strings.TrimSuffix(vaultFileName(list), ".md")
+	path := filepath.Join(v.dir, name+".md")
+	for i := 2; ; i++ {
+		_, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return path, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(v.dir, fmt.Sprintf("%s %d.md", name, i))
+	}
+}
+
+// vaultLines returns the lines of a vault file without the final newline.
+func vaultLines(data string) []string {
+	data = strings.TrimSuffix(strings.ReplaceAll(data, "\r\n", "\n"), "\n")
+	if data == "" {
+		return nil
+	}
+	return strings.Split(data, "\n")
+}
+
+func joinVaultLines(lines []string) string {
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// frontMatterLength returns the number of lines of the front matter at the
+// start of lines, 0 if there is none.
+func frontMatterLength(lines []string) int {
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return 0
+	}
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "---" {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// vaultListID returns the list ID in the front matter of a vault file and
+// whether the front matter has the key at all.
+func vaultListID(data string) (string, bool) {
+	lines := vaultLines(data)
+	n := frontMatterLength(lines)
+	for i := 1; i < n-1; i++ {
+		key, value, ok := strings.Cut(lines[i], ":")
+		if ok && strings.TrimSpace(key) == vaultListKey {
+			return strings.Trim(strings.TrimSpace(value), `"'`), true
+		}
+	}
+	return "", false
+}
+
+// setVaultListID sets the list ID in the front matter of lines, adding the
+// front matter if there is none.
+func setVaultListID(lines []string, listID string) []string {
+	field := vaultListKey + ": " + listID
+	n := frontMatterLength(lines)
+	if n == 0 {
+		return append([]string{"---", field, "---", ""}, lines...)
+	}
+	for i := 1; i < n-1; i++ {
+		key, _, ok := strings.Cut(lines[i], ":")
+		if ok && strings.TrimSpace(key) == vaultListKey {
+			lines[i] = field
+			return lines
+		}
+	}
+	return append(append(lines[:n-1:n-1], field), lines[n-1:]...)
+}
+
+// renderVaultFile returns the file of list with items, keeping the lines of
+// existing, the current content of the file, that are not tasks. The tasks
+// take the places of the tasks in existing in order, and those left over
+// follow the last of them.
+func renderVaultFile(list *TodoList, items []*TodoItem, existing string) string {
+	lines := vaultLines(existing)
+	if len(lines) == 0 {
+		lines = []string{"# " + list.Name}
+	}
+	lines = setVaultListID(lines, list.ID)
+
+	var out []string
+	next, end := 0, -1
+	for _, block := range scanVaultFile(joinVaultLines(lines)) {
+		if block.item == nil {
+			out = append(out, block.lines...)
+			continue
+		}
+		if next < len(items) {
+			out = append(out, renderVaultTask(items[next])...)
+			next++
+		}
+		end = len(out)
+	}
+
+	var rest []string
+	if end < 0 {
+		end = len(out)
+		if end > 0 && out[end-1] != "" && next < len(items) {
+			rest = append(rest, "")
+		}
+	}
+	for _, item := range items[next:] {
+		rest = append(rest, renderVaultTask(item)...)
+	}
+	return joinVaultLines(append(append(out[:end:end], rest...), out[end:]...))
+}
+
+// renderVaultTask returns the lines of item, its checklist line followed by
+// its notes.
+func renderVaultTask(item *TodoItem) []string {
+	lines := []string{renderVaultLine(item)}
+	if item.Notes != "" {
+		for _, line := range strings.Split(item.Notes, "\n") {
+			lines = append(lines, strings.TrimRight("    "+line, " "))
+		}
+	}
+	return lines
+}
+
+func renderVaultLine(item *TodoItem) string {
+	mark := " "
+	if item.Completed {
+		mark = "x"
+	}
+	fields := []string{"- [" + mark + "]"}
+	for _, word := range strings.Fields(item.Title) {
+		if isVaultMetadata(strings.TrimLeft(word, `\`)) {
+			word = `\` + word
+		}
+		fields = append(fields, word)
+	}
+	fields = append(fields, vaultDurationMarker, item.Duration)
+	if !item.DueDate.IsZero() {
+		fields = append(fields, vaultDueMarker, item.DueDate.Format(dueDateLayout))
+	}
+	if marker, ok := vaultPriorityMarkers[item.Priority]; ok {
+		fields = append(fields, marker)
+	}
+	for _, tag := range item.Tags {
+		fields = append(fields, "#"+tag)
+	}
+	fields = append(fields, vaultIDMarker, item.ID.String())
+	return strings.Join(fields, " ")
+}
+
+// isVaultMetadata reports whether parseVaultLine reads word as a tag or
+// marker instead of a word of the title.
+func isVaultMetadata(word string) bool {
+	word = strings.ReplaceAll(word, "\uFE0F", "")
+	switch word {
+	case vaultDurationMarker, vaultDueMarker, vaultIDMarker, "🔺", "⏬":
+		return true
+	}
+	for _, marker := range vaultPriorityMarkers {
+		if word == marker {
+			return true
+		}
+	}
+	return len(word) > 1 && word[0] == '#'
+}
+
+// vaultBlock is a task of a vault file with the lines of its notes, or else
+// one line that is not part of a task.
+type vaultBlock struct {
+	lines []string
+	item  *TodoItem
+}
+
+// scanVaultFile splits a vault file into tasks and other lines, in order.
+// Tasks without an ID have uuid.Nil.
+func scanVaultFile(data string) []vaultBlock {
+	lines := vaultLines(data)
+	n := frontMatterLength(lines)
+	var blocks []vaultBlock
+	for _, line := range lines[:n] {
+		blocks = append(blocks, vaultBlock{lines: []string{line}})
+	}
+
+	var task *vaultBlock
+	var notes, blanks []string
+	finish := func() {
+		if task != nil {
+			task.item.Notes = strings.Join(notes, "\n")
+			blocks = append(blocks, *task)
+		}
+		for _, line := range blanks {
+			blocks = append(blocks, vaultBlock{lines: []string{line}})
+		}
+		task, notes, blanks = nil, nil, nil
+	}
+
+	for _, line := range lines[n:] {
+		switch {
+		case strings.TrimSpace(line) == "":
+			blanks = append(blanks, line)
+		case task != nil && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")):
+			notes = append(notes, make([]string, len(blanks))...)
+			task.lines = append(task.lines, blanks...)
+			blanks = nil
+			task.lines = append(task.lines, line)
+			line = strings.TrimPrefix(line, "\t")
+			for i := 0; i < 4 && strings.HasPrefix(line, " "); i++ {
+				line = line[1:]
+			}
+			notes = append(notes, line)
+		default:
+			finish()
+			var item *TodoItem
+			match := markdownTaskLine.FindStringSubmatch(line)
+			if match != nil && line == strings.TrimLeft(line, " \t") {
+				item = parseVaultLine(match[2])
+			}
+			if item == nil {
+				blocks = append(blocks, vaultBlock{lines: []string{line}})
+				continue
+			}
+			item.Completed = match[1] != " "
+			task = &vaultBlock{lines: []string{line}, item: item}
+		}
+	}
+	finish()
+	return blocks
+}
+
+// parseVaultFile returns the tasks of a vault file in order. Tasks without
+// an ID have uuid.Nil.
+func parseVaultFile(data string) []*TodoItem {
+	var items []*TodoItem
+	for _, block := range scanVaultFile(data) {
+		if block.item != nil {
+			items = append(items, block.item)
+		}
+	}
+	return items
+}
+
+func parseVaultLine(text string) *TodoItem {
+	item := importedTodoItem("")
+	item.ID = uuid.Nil
+
+	var words []string
+	fields := strings.Fields(strings.ReplaceAll(text, "\uFE0F", ""))
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		next := ""
+		if i+1 < len(fields) {
+			next = fields[i+1]
+		}
+		switch {
+		case field == vaultDurationMarker && next != "":
+			remainingTime, err := time.ParseDuration(next)
+			if err != nil {
+				words = append(words, field)
+				continue
+			}
+			item.Duration, item.RemainingTime = next, remainingTime
+			i++
+		case field == vaultDueMarker && next != "":
+			due, err := time.ParseInLocation(dueDateLayout, next, time.Local)
+			if err != nil {
+				words = append(words, field)
+				continue
+			}
+			item.DueDate = due
+			i++
+		case field == vaultIDMarker && next != "":
+			id, err := uuid.Parse(next)
+			if err != nil {
+				words = append(words, field)
+				continue
+			}
+			item.ID = id
+			i++
+		case len(field) > 1 && field[0] == '\\' && isVaultMetadata(strings.TrimLeft(field, `\`)):
+			words = append(words, field[1:])
+		case field == "🔺":
+			item.Priority = 1
+		case field == "⏬":
+			item.Priority = defaultPriority
+		case len(field) > 1 && field[0] == '#':
+			item.Tags = append(item.Tags, field[1:])
+		default:
+			priority := 0
+			for p, marker := range vaultPriorityMarkers {
+				if field == marker {
+					priority = p
+				}
+			}
+			if priority > 0 {
+				item.Priority = priority
+			} else {
+				words = append(words, field)
+			}
+		}
+	}
+	item.Title = strings.Join(words, " ")
+	if item.Title == "" {
+		return nil
+	}
+	return item
+}
+
+// applyTasks stores the tasks parsed from the file of list in the list and
+// returns the tasks that were added, changed or archived.
+func (v *vault) applyTasks(list *TodoList, parsed []*TodoItem) ([]*TodoItem, error) {
+	tasks := map[uuid.UUID]*TodoItem{}
+	active, err := getAllTodoItems()
+	if err != nil {
+		return nil, err
+	}
+	archived, err := getArchivedTodoItems()
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range append(active, archived...) {
+		tasks[item.ID] = item
+	}
+
+	var changed, order []*TodoItem
+	seen := map[uuid.UUID]bool{}
+	for _, parsed := range parsed {
+		existing := tasks[parsed.ID]
+		if existing == nil || seen[parsed.ID] {
+			if parsed.ID == uuid.Nil || seen[parsed.ID] {
+				parsed.ID = uuid.New()
+			}
+			parsed.ListID = list.ID
+			err = saveTodoItem(parsed)
+			if err != nil {
+				return changed, err
+			}
+			seen[parsed.ID] = true
+			changed = append(changed, parsed)
+			order = append(order, parsed)
+			continue
+		}
+		seen[parsed.ID] = true
+		order = append(order, existing)
+
+		edited := false
+		if !existing.ArchivedAt.IsZero() {
+			err = restoreTodoItem(existing)
+			if err != nil {
+				return changed, err
+			}
+			edited = true
+		}
+		// The vault has no recurrence; keep what iCalendar brought in.
+		parsed.Recurrence = existing.Recurrence
+		if todoItemHash(parsed) != todoItemHash(existing) || existing.ListID != list.ID {
+			copyEditableFields(existing, parsed)
+			existing.ListID = list.ID
+			err = updateTodoItem(existing)
+			if err == nil {
+				err = updateRemainingTime(existing)
+			}
+			if err != nil {
+				return changed, err
+			}
+			edited = true
+		}
+		if edited {
+			changed = append(changed, existing)
+		}
+	}
+
+	for _, item := range active {
+		if item.ListID == list.ID && !seen[item.ID] {
+			err = archiveTodoItem(item)
+			if err != nil {
+				return changed, err
+			}
+			changed = append(changed, item)
+		}
+	}
+
+	// The file order is the manual order.
+	moved := false
+	for i, item := range order {
+		if item.Position != float64(i+1) {
+			item.Position = float64(i + 1)
+			moved = true
+		}
+	}
+	if moved {
+		err = updatePositions(order)
+	}
+	return changed, err
+}
+
+// listForFile returns the list of the vault file at path with listID in its
+// front matter, creating it if there is none. Without an ID the list is the
+// one the file is named after.
+func (v *vault) listForFile(path, listID string) (*TodoList, error) {
+	lists, err := getTodoLists()
+	if err != nil {
+		return nil, err
+	}
+	for _, list := range lists {
+		if list.ID == listID {
+			return list, nil
+		}
+	}
+	if listID == "" {
+		list := listByFileName(lists, path)
+		if list != nil {
+			return list, nil
+		}
+		listID = uuid.New().String()
+	}
+
+	list := &TodoList{ID: listID, Name: strings.TrimSuffix(filepath.Base(path), ".md"), SortMode: sortByManual}
+	return list, saveTodoList(list)
+}
+
+func listByFileName(lists []*TodoList, path string) *TodoList {
+	for _, list := range lists {
+		if strings.EqualFold(vaultFileName(list), filepath.Base(path)) {
+			return list
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func openTestVault(t *testing.T, dir string) *vault {
+	t.Helper()
+	previous := todosChanged
+	t.Cleanup(func() { todosChanged = previous })
+	v, err := openVault(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { v.close() })
+	return v
+}
+
+func TestVaultLeavesOtherNotes(t *testing.T) {
+	openTestDB(t)
+	dir := t.TempDir()
+	note := "# Ideas\n\n- [ ] Not a GoDo task\n"
+	err := os.WriteFile(filepath.Join(dir, "Ideas.md"), []byte(note), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A note named like a list is not taken for its file either.
+	err = os.WriteFile(filepath.Join(dir, "Inbox.md"), []byte(note), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	openTestVault(t, dir)
+
+	for _, name := range []string{"Ideas.md", "Inbox.md"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != note {
+			t.Errorf("%s was changed to %q", name, data)
+		}
+	}
+	lists, err := getTodoLists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 1 {
+		t.Errorf("got %d lists, want only the default one", len(lists))
+	}
+	_, err = os.Stat(filepath.Join(dir, "Inbox 2.md"))
+	if err != nil {
+		t.Errorf("the default list was not written next to the note: %v", err)
+	}
+}
+
+func TestVaultKeepsOtherLines(t *testing.T) {
+	openTestDB(t)
+	v := openTestVault(t, t.TempDir())
+	lists, err := getTodoLists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(v.dir, vaultFileName(lists[0]))
+	data := "---\n" + vaultListKey + ": " + lists[0].ID + "\ntags: [plan]\n---\n\n# Plan\n\nSome prose.\n\n- [ ] Fix bug #1 in the parser #work\n    A note.\n\n## Later\n\n- [ ] Write \\#docs\n\nClosing words.\n"
+	err = os.WriteFile(path, []byte(data), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = v.sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := getTodoItems(lists[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("got %d tasks, want 2", len(items))
+	}
+	if items[0].Title != "Fix bug in the parser" || strings.Join(items[0].Tags, ",") != "1,work" || items[0].Notes != "A note." {
+		t.Errorf("first task: got %q with tags %v and notes %q", items[0].Title, items[0].Tags, items[0].Notes)
+	}
+	if items[1].Title != "Write #docs" {
+		t.Errorf("second task: got %q, want the escaped # kept in the title", items[1].Title)
+	}
+
+	items[1].Title = "Write #docs for #2"
+	err = updateTodoItem(items[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = v.sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"tags: [plan]", "Some prose.", "## Later", "Closing words.", "    A note.", `- [ ] Write \#docs for \#2 ⏱`} {
+		if !strings.Contains(string(written), line+"\n") && !strings.Contains(string(written), line+" ") {
+			t.Errorf("the written file lacks %q:\n%s", line, written)
+		}
+	}
+	if strings.Index(string(written), "## Later") > strings.Index(string(written), "Write ") {
+		t.Errorf("the second task moved above the heading:\n%s", written)
+	}
+
+	parsed := parseVaultFile(string(written))
+	if len(parsed) != 2 || parsed[1].Title != "Write #docs for #2" || len(parsed[1].Tags) != 0 {
+		t.Errorf("the written file reads back as %+v", parsed)
+	}
+}
+
+func TestVaultWritesChangesOnClose(t *testing.T) {
+	openTestDB(t)
+	v := openTestVault(t, t.TempDir())
+	lists, err := getTodoLists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addTestTodoItem(t, "Water the plants", lists[0].ID)
+	addTestTodoItem(t, "Call the plumber", lists[0].ID)
+
+	// The changes wait for vaultDebounce, but closing writes them at once.
+	err = v.close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := os.ReadFile(filepath.Join(v.dir, vaultFileName(lists[0])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{"Water the plants", "Call the plumber"} {
+		if !strings.Contains(string(written), "- [ ] "+title) {
+			t.Errorf("the file lacks %q:\n%s", title, written)
+		}
+	}
+}